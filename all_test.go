@@ -1,13 +1,22 @@
 package autoconfig
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"flag"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -468,3 +477,1976 @@ func TestReadFileMalformedConfigReturnsSpecificError(t *testing.T) {
 		t.Fatalf("expected ConfigParseError, got %T: %v", err, err)
 	}
 }
+
+// formatAppConfig is decoded from every built-in file format.
+type formatAppConfig struct {
+	Address  string             `yaml:"address" json:"address" toml:"address" mapstructure:"ADDRESS" config:"required"`
+	Port     int                `yaml:"port" json:"port" toml:"port" mapstructure:"PORT" config:"default=8000"`
+	Origin   []string           `yaml:"origin" json:"origin" toml:"origin" mapstructure:"ORIGIN"`
+	Enabled  bool               `yaml:"enabled" json:"enabled" toml:"enabled" mapstructure:"ENABLED" config:"required"`
+	Features formatNestedConfig `yaml:"features" json:"features" toml:"features" config:"struct,required"`
+}
+
+type formatNestedConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled" toml:"enabled" mapstructure:"ENABLED" config:"required"`
+}
+
+func TestReadFileSupportsTOMLINIAndProperties(t *testing.T) {
+	cases := []struct {
+		cfgType ConfigType
+		content string
+	}{
+		{ConfigTypeTOML, "address = \"10.0.0.1\"\nport = 9000\norigin = [\"a\", \"b\"]\nenabled = false\n\n[features]\nenabled = false\n"},
+		{ConfigTypeINI, "; service settings\naddress = 10.0.0.1\nport = 9000\norigin = a, b\nenabled = false\n\n[features]\nenabled = false\n"},
+		{ConfigTypeProperties, "# service settings\naddress=10.0.0.1\nport: 9000\norigin = a, \\\n  b\nenabled = false\nfeatures.enabled = false\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.cfgType.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := New("FMTTEST")
+			if err := cfg.Create("app", "config", dir, tc.cfgType); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "config."+tc.cfgType.String()), []byte(tc.content), 0o600); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+
+			app := new(formatAppConfig)
+			if err := cfg.ReadFile(app); err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+			if err := cfg.Check(app); err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if app.Address != "10.0.0.1" || app.Port != 9000 {
+				t.Fatalf("unexpected values: %+v", app)
+			}
+			if !reflect.DeepEqual(app.Origin, []string{"a", "b"}) {
+				t.Fatalf("unexpected origin slice: %#v", app.Origin)
+			}
+			if app.Enabled || app.Features.Enabled {
+				t.Fatalf("expected explicit false values to be preserved: %+v", app)
+			}
+		})
+	}
+}
+
+func TestReadFileRejectsUnknownINIKeys(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("APP")
+	if err := cfg.Create("app", "config", dir, ConfigTypeINI); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	content := "address = 127.0.0.1\nenabled = true\n\n[features]\nenabled = true\nunknown = nope\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.ini"), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := cfg.ReadFile(new(formatAppConfig)); err == nil {
+		t.Fatalf("ReadFile succeeded unexpectedly")
+	}
+}
+
+// jsoncCodec is a JSON dialect that allows whole-line // comments.
+type jsoncCodec struct{}
+
+func (jsoncCodec) Decode(data []byte) (map[string]any, error) {
+	var kept [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("//")) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	settings := make(map[string]any)
+	if err := json.Unmarshal(bytes.Join(kept, []byte("\n")), &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (jsoncCodec) Encode(settings map[string]any) ([]byte, error) {
+	return json.Marshal(settings)
+}
+
+func (jsoncCodec) Extensions() []string { return []string{"jsonc"} }
+func (jsoncCodec) TagName() string      { return "jsonc" }
+
+var configTypeJSONC = func() ConfigType {
+	ct, err := RegisterCodec("jsonc", jsoncCodec{})
+	if err != nil {
+		panic(err)
+	}
+	return ct
+}()
+
+func TestRegisterCodecReadsCustomFormat(t *testing.T) {
+	if !configTypeJSONC.IsValid() || configTypeJSONC.String() != "jsonc" {
+		t.Fatalf("unexpected registered config type %d (%s)", configTypeJSONC, configTypeJSONC)
+	}
+	if _, err := RegisterCodec("JSONC", jsoncCodec{}); err == nil {
+		t.Fatalf("expected duplicate registration to fail")
+	}
+
+	dir := t.TempDir()
+	cfg := New("CODECTEST")
+	if err := cfg.Create("app", "config", dir, configTypeJSONC); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	content := "{\n  // listen address\n  \"address\": \"10.0.0.2\",\n  \"enabled\": false,\n  \"features\": {\"enabled\": false}\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.jsonc"), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(fileAppConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if app.Address != "10.0.0.2" {
+		t.Fatalf("unexpected address: %q", app.Address)
+	}
+}
+
+// layeredConfig is read from several file layers.
+type layeredConfig struct {
+	Address string `yaml:"address" mapstructure:"ADDRESS" config:"required"`
+	Port    int    `yaml:"port" mapstructure:"PORT" config:"default=8000"`
+	Debug   bool   `yaml:"debug" mapstructure:"DEBUG" config:"default=true"`
+}
+
+func TestReadFileMergesSourcesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"system.yaml":   "address: system-address\nport: 7000\ndebug: false\n",
+		"user.yaml":     "address: user-address\n",
+		"override.json": "{\"port\": 9000}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	cfg := New("LAYERTEST")
+	err := cfg.SetFileSources(
+		FileSource{Path: filepath.Join(dir, "system.yaml"), Optional: true},
+		FileSource{Path: filepath.Join(dir, "missing.yaml"), Optional: true},
+		FileSource{Path: filepath.Join(dir, "user.yaml"), Optional: true},
+		FileSource{Path: filepath.Join(dir, "override.json")},
+	)
+	if err != nil {
+		t.Fatalf("SetFileSources failed: %v", err)
+	}
+
+	app := new(layeredConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if app.Address != "user-address" || app.Port != 9000 {
+		t.Fatalf("unexpected merged values: %+v", app)
+	}
+	// debug: false comes from the lowest layer only; its presence must still
+	// stop the default from being applied.
+	if app.Debug {
+		t.Fatalf("expected explicit false from system layer to be preserved")
+	}
+	if got := cfg.LoadedFiles(); len(got) != 3 || got[2] != filepath.Join(dir, "override.json") {
+		t.Fatalf("unexpected loaded files: %v", got)
+	}
+}
+
+func TestReadFileFailsWhenRequiredSourceIsMissing(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("LAYERTEST2")
+	if err := cfg.SetFileSources(FileSource{Path: filepath.Join(dir, "missing.yaml")}); err != nil {
+		t.Fatalf("SetFileSources failed: %v", err)
+	}
+	if err := cfg.ReadFile(new(layeredConfig)); err == nil {
+		t.Fatalf("ReadFile succeeded unexpectedly")
+	}
+	if err := cfg.SetFileSources(FileSource{Path: "relative.yaml"}); err == nil {
+		t.Fatalf("expected relative source path to be rejected")
+	}
+}
+
+func TestReadFileMergesDropInFragmentsInLexicalOrder(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("DROPTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := cfg.EnableDropIns(""); err != nil {
+		t.Fatalf("EnableDropIns failed: %v", err)
+	}
+
+	dropInDir := filepath.Join(dir, "config.d")
+	if err := os.Mkdir(dropInDir, 0o700); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "config.yaml"):           "address: base-address\nport: 7000\n",
+		filepath.Join(dropInDir, "20-port.yaml"):    "port: 9000\n",
+		filepath.Join(dropInDir, "10-address.json"): `{"address": "fragment-address", "port": 8000}`,
+		filepath.Join(dropInDir, "README"):          "not a fragment",
+		filepath.Join(dropInDir, ".hidden.yaml"):    "unknown: ignored\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	app := new(layeredConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Address != "fragment-address" || app.Port != 9000 {
+		t.Fatalf("unexpected merged values: %+v", app)
+	}
+
+	bad := filepath.Join(dropInDir, "30-bad.yaml")
+	if err := os.WriteFile(bad, []byte("adress: typo\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	cfg2 := New("DROPTEST")
+	if err := cfg2.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := cfg2.EnableDropIns(dropInDir); err != nil {
+		t.Fatalf("EnableDropIns failed: %v", err)
+	}
+	err := cfg2.ReadFile(new(layeredConfig))
+	if err == nil || !strings.Contains(err.Error(), "30-bad.yaml") {
+		t.Fatalf("expected error naming the bad fragment, got %v", err)
+	}
+}
+
+// flagConfig is populated from command-line flags.
+type flagConfig struct {
+	Address  string        `yaml:"address" mapstructure:"ADDRESS" config:"required"`
+	Port     int           `yaml:"port" mapstructure:"PORT" config:"default=8000"`
+	Verbose  bool          `yaml:"verbose" mapstructure:"VERBOSE"`
+	Timeout  time.Duration `yaml:"timeout" mapstructure:"TIMEOUT" config:"default=5s"`
+	Internal string        `mapstructure:"INTERNAL" config:"-"`
+}
+
+func TestFlagsOverrideEnvAndFileValues(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("FLAGTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("address: file-address\nport: 7000\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("FLAGTEST_ADDRESS", "env-address")
+	t.Setenv("FLAGTEST_PORT", "7500")
+
+	app := new(flagConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if err := cfg.ReadFlags(app, []string{"-port", "9000", "-verbose", "-timeout=1m"}); err != nil {
+		t.Fatalf("ReadFlags failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if app.Address != "env-address" {
+		t.Fatalf("expected env-address (env overrides file, no flag), got %q", app.Address)
+	}
+	if app.Port != 9000 || !app.Verbose || app.Timeout != time.Minute {
+		t.Fatalf("expected flag values to win, got %+v", app)
+	}
+}
+
+func TestFlagPresenceSatisfiesRequired(t *testing.T) {
+	cfg := New("FLAGTEST2")
+	app := new(flagConfig)
+	if err := cfg.ReadFlags(app, []string{"-address", "flag-address"}); err != nil {
+		t.Fatalf("ReadFlags failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if app.Address != "flag-address" || app.Port != 8000 {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+}
+
+func TestRegisterFlagsValidatesTypesAndSkipsExcludedFields(t *testing.T) {
+	cfg := New("FLAGTEST3")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := cfg.RegisterFlags(fs, new(flagConfig)); err != nil {
+		t.Fatalf("RegisterFlags failed: %v", err)
+	}
+	if fs.Lookup("internal") != nil {
+		t.Fatalf("expected config:\"-\" field to have no flag")
+	}
+	if f := fs.Lookup("port"); f == nil || f.DefValue != "8000" {
+		t.Fatalf("expected -port flag with default 8000, got %+v", f)
+	}
+	if err := fs.Parse([]string{"-port", "not-a-number"}); err == nil {
+		t.Fatalf("expected invalid int flag value to be rejected")
+	}
+}
+
+// nestedFlagConfig has a nested field whose key no other field shares.
+type nestedFlagConfig struct {
+	Port int           `yaml:"port" mapstructure:"PORT"`
+	Log  nestedFlagLog `yaml:"log" mapstructure:"LOG" config:"struct"`
+}
+
+type nestedFlagLog struct {
+	Level string `yaml:"level" mapstructure:"LEVEL"`
+}
+
+func TestFlagsSetNestedFields(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("FLAGTEST4")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(nestedFlagConfig)
+	if err := cfg.ReadFlags(app, []string{"-level=info"}); err != nil {
+		t.Fatalf("ReadFlags failed: %v", err)
+	}
+	if app.Log.Level != "info" {
+		t.Fatalf("expected flag to set Log.Level, got %q", app.Log.Level)
+	}
+
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Log.Level != "info" {
+		t.Fatalf("expected flag to override file value, got %q", app.Log.Level)
+	}
+}
+
+func TestBoundPFlagsOverrideEnvAndFileValues(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("PFLAGTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("port: 7000\ntimeout: 2s\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("PFLAGTEST_ADDRESS", "env-address")
+	t.Setenv("PFLAGTEST_PORT", "7500")
+
+	app := new(flagConfig)
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := cfg.BindPFlags(fs, app); err != nil {
+		t.Fatalf("BindPFlags failed: %v", err)
+	}
+	if f := fs.Lookup("timeout"); f == nil || f.DefValue != "5s" {
+		t.Fatalf("expected --timeout flag with default 5s, got %+v", f)
+	}
+	if err := fs.Parse([]string{"--port=9000", "--verbose"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if err := cfg.ApplyPFlags(app); err != nil {
+		t.Fatalf("ApplyPFlags failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if app.Address != "env-address" || app.Port != 9000 || !app.Verbose {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+	if app.Timeout != 2*time.Second {
+		t.Fatalf("expected unset flag default not to override file value, got %s", app.Timeout)
+	}
+}
+
+func TestBoundPFlagPresenceSatisfiesRequired(t *testing.T) {
+	cfg := New("PFLAGTEST2")
+	app := new(flagConfig)
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := cfg.BindPFlags(fs, app); err != nil {
+		t.Fatalf("BindPFlags failed: %v", err)
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.ApplyPFlags(app); err != nil {
+		t.Fatalf("ApplyPFlags failed: %v", err)
+	}
+	if err := cfg.Check(app); err == nil {
+		t.Fatalf("expected Check to fail without --address")
+	}
+
+	if err := fs.Parse([]string{"--address", "flag-address"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.ApplyPFlags(app); err != nil {
+		t.Fatalf("ApplyPFlags failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if app.Address != "flag-address" {
+		t.Fatalf("unexpected address: %q", app.Address)
+	}
+}
+
+func TestBoundPFlagsBindNestedFields(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("PFLAGTEST3")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(nestedFlagConfig)
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := cfg.BindPFlags(fs, app); err != nil {
+		t.Fatalf("BindPFlags failed: %v", err)
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Log.Level != "debug" {
+		t.Fatalf("expected file value without --level, got %q", app.Log.Level)
+	}
+
+	if err := fs.Parse([]string{"--level=info"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.ApplyPFlags(app); err != nil {
+		t.Fatalf("ApplyPFlags failed: %v", err)
+	}
+	if app.Log.Level != "info" {
+		t.Fatalf("expected --level to set Log.Level, got %q", app.Log.Level)
+	}
+}
+
+type dotEnvConfig struct {
+	Address string   `yaml:"address" mapstructure:"ADDRESS" config:"required"`
+	Port    int      `yaml:"port" mapstructure:"PORT" config:"default=8000"`
+	Message string   `yaml:"message" mapstructure:"MESSAGE"`
+	Origin  []string `yaml:"origin" mapstructure:"ORIGIN"`
+	URL     string   `yaml:"url" mapstructure:"URL"`
+}
+
+func TestParseDotEnvSyntax(t *testing.T) {
+	content := strings.Join([]string{
+		"# comment",
+		"export HOST=example.com",
+		"PORT = 8080 # trailing comment",
+		`SINGLE='literal $HOST \n'`,
+		`DOUBLE="line one\nline \"two\" \$HOST"`,
+		`MULTI="first`,
+		`second"`,
+		"URL=http://${HOST}:$PORT/${MISSING:-fallback}",
+		"EMPTY=",
+	}, "\n")
+	lookup := func(string) (string, bool) { return "", false }
+
+	vars, err := parseDotEnv(content, lookup)
+	if err != nil {
+		t.Fatalf("parseDotEnv failed: %v", err)
+	}
+
+	want := map[string]string{
+		"HOST":   "example.com",
+		"PORT":   "8080",
+		"SINGLE": `literal $HOST \n`,
+		"DOUBLE": "line one\nline \"two\" $HOST",
+		"MULTI":  "first\nsecond",
+		"URL":    "http://example.com:8080/fallback",
+		"EMPTY":  "",
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Fatalf("unexpected vars:\n got %#v\nwant %#v", vars, want)
+	}
+
+	if _, err := parseDotEnv("NOT VALID", lookup); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected line-numbered error, got %v", err)
+	}
+	if _, err := parseDotEnv(`KEY="unterminated`, lookup); err == nil {
+		t.Fatalf("expected error for unterminated quote")
+	}
+}
+
+func TestReadDotEnvRanksBetweenEnvAndFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("DOTENVTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("address: file-address\nport: 7000\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	dotenv := filepath.Join(dir, ".env")
+	content := "DOTENVTEST_ADDRESS=dotenv-address\nDOTENVTEST_PORT=7500\nexport DOTENVTEST_ORIGIN=a,b\nDOTENVTEST_MESSAGE=\nOTHER=ignored\n"
+	if err := os.WriteFile(dotenv, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("DOTENVTEST_PORT", "9000")
+
+	app := new(dotEnvConfig)
+	if err := cfg.ReadDotEnv(dotenv, app); err != nil {
+		t.Fatalf("ReadDotEnv failed: %v", err)
+	}
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if app.Address != "dotenv-address" {
+		t.Fatalf("expected dotenv to override file, got %q", app.Address)
+	}
+	if app.Port != 9000 {
+		t.Fatalf("expected environment to override dotenv, got %d", app.Port)
+	}
+	if !reflect.DeepEqual(app.Origin, []string{"a", "b"}) {
+		t.Fatalf("unexpected origin: %#v", app.Origin)
+	}
+	if _, ok := os.LookupEnv("DOTENVTEST_ADDRESS"); ok {
+		t.Fatalf("ReadDotEnv must not modify the process environment")
+	}
+}
+
+func TestReadDotEnvPresenceSatisfiesRequired(t *testing.T) {
+	dotenv := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(dotenv, []byte(`DOTENVTEST2_ADDRESS="quoted address"`+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg := New("DOTENVTEST2")
+	app := new(dotEnvConfig)
+	if err := cfg.ReadDotEnv(dotenv, app); err != nil {
+		t.Fatalf("ReadDotEnv failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if app.Address != "quoted address" || app.Port != 8000 {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+}
+
+func TestReadDotEnvKeepsAppliedFlags(t *testing.T) {
+	dotenv := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(dotenv, []byte("DOTENVTEST3_PORT=8\nDOTENVTEST3_ADDRESS=dotenv-address\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg := New("DOTENVTEST3")
+	app := new(dotEnvConfig)
+	if err := cfg.ReadFlags(app, []string{"-port=9"}); err != nil {
+		t.Fatalf("ReadFlags failed: %v", err)
+	}
+	if err := cfg.ReadDotEnv(dotenv, app); err != nil {
+		t.Fatalf("ReadDotEnv failed: %v", err)
+	}
+	if app.Port != 9 {
+		t.Fatalf("expected flag to override dotenv, got %d", app.Port)
+	}
+	if app.Address != "dotenv-address" {
+		t.Fatalf("expected dotenv value without a flag, got %q", app.Address)
+	}
+}
+
+func TestReadDotEnvSetsNestedFields(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("DOTENVTEST4")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte("log:\n  level: info\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	dotenv := filepath.Join(dir, ".env")
+	if err := os.WriteFile(dotenv, []byte("DOTENVTEST4_LEVEL=debug\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(nestedFlagConfig)
+	if err := cfg.ReadDotEnv(dotenv, app); err != nil {
+		t.Fatalf("ReadDotEnv failed: %v", err)
+	}
+	if app.Log.Level != "debug" {
+		t.Fatalf("expected dotenv to set Log.Level, got %q", app.Log.Level)
+	}
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Log.Level != "debug" {
+		t.Fatalf("expected dotenv to override file value, got %q", app.Log.Level)
+	}
+}
+
+type fileEnvConfig struct {
+	Password string `mapstructure:"PASSWORD" config:"required,file"`
+	Token    string `mapstructure:"TOKEN"`
+}
+
+func TestReadEnvReadsFileSuffixForOptedInField(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "password")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	token := filepath.Join(dir, "token")
+	if err := os.WriteFile(token, []byte("tok\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("FILEENVTEST_PASSWORD_FILE", secret)
+	t.Setenv("FILEENVTEST_TOKEN_FILE", token)
+
+	cfg := New("FILEENVTEST")
+	app := new(fileEnvConfig)
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if app.Password != "s3cret" {
+		t.Fatalf("expected password from file, got %q", app.Password)
+	}
+	if app.Token != "" {
+		t.Fatalf("expected token without opt-in to stay empty, got %q", app.Token)
+	}
+
+	cfg = New("FILEENVTEST")
+	cfg.SetFileEnv(true)
+	app = new(fileEnvConfig)
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if app.Token != "tok" {
+		t.Fatalf("expected token from file with SetFileEnv, got %q", app.Token)
+	}
+}
+
+func TestReadEnvPlainVariableWinsOverFileSuffix(t *testing.T) {
+	t.Setenv("FILEENVTEST2_PASSWORD", "direct")
+	t.Setenv("FILEENVTEST2_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	cfg := New("FILEENVTEST2")
+	app := new(fileEnvConfig)
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if app.Password != "direct" {
+		t.Fatalf("expected plain env var to win, got %q", app.Password)
+	}
+}
+
+func TestReadEnvFileSuffixKeepsAppliedFlags(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(secret, []byte("fromfile\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("FILEENVTEST4_PASSWORD_FILE", secret)
+
+	cfg := New("FILEENVTEST4")
+	app := new(fileEnvConfig)
+	if err := cfg.ReadFlags(app, []string{"-password=fromflag"}); err != nil {
+		t.Fatalf("ReadFlags failed: %v", err)
+	}
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if app.Password != "fromflag" {
+		t.Fatalf("expected flag to override secret file, got %q", app.Password)
+	}
+}
+
+// nestedSecretConfig holds a _FILE secret inside a nested struct.
+type nestedSecretConfig struct {
+	DB nestedSecretDB `yaml:"db" mapstructure:"DB" config:"struct"`
+}
+
+type nestedSecretDB struct {
+	Password string `yaml:"password" mapstructure:"PASSWORD" config:"required,file"`
+}
+
+func TestReadEnvFileSuffixSetsNestedFields(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "password")
+	if err := os.WriteFile(secret, []byte("fromfile\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("FILEENVTEST5_PASSWORD_FILE", secret)
+
+	cfg := New("FILEENVTEST5")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte("db:\n  password: infile\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(nestedSecretConfig)
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if app.DB.Password != "fromfile" {
+		t.Fatalf("expected secret file to set DB.Password, got %q", app.DB.Password)
+	}
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if app.DB.Password != "fromfile" {
+		t.Fatalf("expected secret file to override file value, got %q", app.DB.Password)
+	}
+}
+
+func TestReadEnvFileSuffixUnreadableNamesVariable(t *testing.T) {
+	t.Setenv("FILEENVTEST3_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	cfg := New("FILEENVTEST3")
+	err := cfg.ReadEnv(new(fileEnvConfig))
+	if err == nil {
+		t.Fatalf("expected error for unreadable secret file")
+	}
+	if !strings.Contains(err.Error(), "FILEENVTEST3_PASSWORD_FILE") || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected error naming the env var, got %v", err)
+	}
+}
+
+type keyDirNestedConfig struct {
+	Enabled bool          `mapstructure:"ENABLED" config:"required"`
+	Timeout time.Duration `mapstructure:"TIMEOUT"`
+}
+
+type keyDirConfig struct {
+	Address  string             `mapstructure:"ADDRESS" config:"required"`
+	Port     int                `mapstructure:"PORT" config:"default=8000"`
+	Origin   []string           `mapstructure:"ORIGIN"`
+	Features keyDirNestedConfig `config:"struct,required"`
+}
+
+func writeKeyFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+}
+
+func TestReadKeyDirDecodesFilesAndRecordsPresence(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyFiles(t, dir, map[string]string{
+		"ADDRESS":          "10.0.0.1\n",
+		"origin":           "a,b",
+		"features.enabled": "true\n",
+		"features.timeout": "30s",
+		"unrelated":        "ignored",
+		".hidden":          "ignored",
+	})
+
+	cfg := New("KEYDIRTEST")
+	app := new(keyDirConfig)
+	if err := cfg.ReadKeyDir(dir, app); err != nil {
+		t.Fatalf("ReadKeyDir failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if app.Address != "10.0.0.1" || app.Port != 8000 {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+	if !reflect.DeepEqual(app.Origin, []string{"a", "b"}) {
+		t.Fatalf("unexpected origin: %#v", app.Origin)
+	}
+	if !app.Features.Enabled || app.Features.Timeout != 30*time.Second {
+		t.Fatalf("unexpected nested values: %+v", app.Features)
+	}
+}
+
+func TestReadKeyDirFollowsKubernetesDataSymlink(t *testing.T) {
+	dir := t.TempDir()
+	revision := filepath.Join(dir, "..2026_10_16_12_00_00.000000001")
+	if err := os.Mkdir(revision, 0o700); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	writeKeyFiles(t, revision, map[string]string{"ADDRESS": "from-configmap", "PORT": "9000"})
+	if err := os.Symlink(filepath.Base(revision), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	for _, name := range []string{"ADDRESS", "PORT"} {
+		if err := os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Symlink failed: %v", err)
+		}
+	}
+
+	cfg := New("KEYDIRTEST2")
+	app := new(keyDirConfig)
+	if err := cfg.ReadKeyDir(dir, app); err != nil {
+		t.Fatalf("ReadKeyDir failed: %v", err)
+	}
+	if app.Address != "from-configmap" || app.Port != 9000 {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+}
+
+func TestReadKeyDirRejectsInvalidValue(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyFiles(t, dir, map[string]string{"PORT": "not-a-number"})
+
+	cfg := New("KEYDIRTEST3")
+	if err := cfg.ReadKeyDir(dir, new(keyDirConfig)); err == nil {
+		t.Fatalf("expected error for invalid port value")
+	}
+}
+
+func TestReadKeyDirRejectedValuesAreNotPresent(t *testing.T) {
+	dir := t.TempDir()
+	writeKeyFiles(t, dir, map[string]string{"ADDRESS": "0.0.0.0", "features.enabled": "maybe"})
+
+	cfg := New("KEYDIRTEST4")
+	app := new(keyDirConfig)
+	if err := cfg.ReadKeyDir(dir, app); err == nil {
+		t.Fatalf("expected error for invalid enabled value")
+	}
+	err := cfg.Check(app)
+	var missing *MissingFieldError
+	if !errors.As(err, &missing) || !strings.Contains(err.Error(), "Features.Enabled") {
+		t.Fatalf("expected Check to report the rejected required field, got %v", err)
+	}
+}
+
+func TestConfigEnvOverridesFilePathAndType(t *testing.T) {
+	dir := t.TempDir()
+	override := filepath.Join(dir, "elsewhere", "app.toml")
+	if err := os.MkdirAll(filepath.Dir(override), 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(override, []byte("address = \"toml-address\"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("CFGENVTEST_CONFIG", override)
+
+	cfg := New("CFGENVTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	app := new(envOverridesFileConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Address != "toml-address" {
+		t.Fatalf("expected value from override file, got %q", app.Address)
+	}
+	if got := cfg.LoadedFiles(); len(got) != 1 || got[0] != override {
+		t.Fatalf("unexpected loaded files: %v", got)
+	}
+}
+
+func TestConfigEnvNameIsConfigurableAndRequiresAbsolutePath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CFGENVTEST2_CONFIG", "relative/app.yaml")
+
+	cfg := New("CFGENVTEST2")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err == nil || !strings.Contains(err.Error(), "CFGENVTEST2_CONFIG") {
+		t.Fatalf("expected absolute path error naming the variable, got %v", err)
+	}
+
+	t.Setenv("MY_APP_CONFIG_PATH", filepath.Join(dir, "custom.json"))
+	cfg = New("CFGENVTEST2")
+	cfg.SetConfigEnv("MY_APP_CONFIG_PATH")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := cfg.configFilePath(); got != filepath.Join(dir, "custom.json") || cfg.cfgType != ConfigTypeJSON {
+		t.Fatalf("unexpected path %q or type %v", got, cfg.cfgType)
+	}
+}
+
+func TestConfigEnvIsOffWithoutPrefix(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONFIG", "/etc/something")
+
+	cfg := New("")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("expected bare CONFIG to be ignored without a prefix, got %v", err)
+	}
+	if got := cfg.configFilePath(); got != filepath.Join(dir, "config.yaml") {
+		t.Fatalf("unexpected path %q", got)
+	}
+
+	t.Setenv("CONFIG", filepath.Join(dir, "custom.json"))
+	cfg = New("")
+	cfg.SetConfigEnv("CONFIG")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := cfg.configFilePath(); got != filepath.Join(dir, "custom.json") {
+		t.Fatalf("expected SetConfigEnv to enable the override, got %q", got)
+	}
+}
+
+func TestCreateXDGLocationSearchesConfigDirs(t *testing.T) {
+	home := t.TempDir()
+	system := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CONFIG_DIRS", "relative/ignored"+string(filepath.ListSeparator)+system)
+
+	if err := os.MkdirAll(filepath.Join(system, "my-app"), 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(system, "my-app", "config.yaml"), []byte("address: system-address\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg := New("XDGTEST")
+	cfg.SetLocation(LocationXDG)
+	if err := cfg.Create("My App", "config", "", ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if want := filepath.Join(home, ".config", "my-app"); cfg.dirname != want {
+		t.Fatalf("expected XDG directory %q, got %q", want, cfg.dirname)
+	}
+	if cfg.LegacyFile() != "" {
+		t.Fatalf("unexpected legacy file %q", cfg.LegacyFile())
+	}
+
+	app := new(envOverridesFileConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Address != "system-address" {
+		t.Fatalf("expected fallback to XDG_CONFIG_DIRS, got %q", app.Address)
+	}
+
+	if err := os.WriteFile(filepath.Join(cfg.dirname, "config.yaml"), []byte("address: user-address\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	app = new(envOverridesFileConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Address != "user-address" {
+		t.Fatalf("expected user file to win, got %q", app.Address)
+	}
+}
+
+func TestCreateXDGLocationReportsLegacyFile(t *testing.T) {
+	home := t.TempDir()
+	configHome := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	legacy := filepath.Join(home, ".app", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(legacy, []byte("address: legacy\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg := New("XDGTEST2")
+	cfg.SetLocation(LocationXDG)
+	if err := cfg.Create("app", "config", "", ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if cfg.dirname != filepath.Join(configHome, "app") {
+		t.Fatalf("unexpected directory %q", cfg.dirname)
+	}
+	if cfg.LegacyFile() != legacy {
+		t.Fatalf("expected legacy file %q, got %q", legacy, cfg.LegacyFile())
+	}
+}
+
+func chdirForTest(t *testing.T, dir string) {
+	t.Helper()
+	prev, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir failed: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(prev) })
+}
+
+func TestDiscoverWalksUpToProjectFile(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	repo := filepath.Join(root, "repo")
+	nested := filepath.Join(repo, "a", "b")
+	if err := os.MkdirAll(nested, 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	file := filepath.Join(repo, ".app.yaml")
+	if err := os.WriteFile(file, []byte("address: discovered\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	chdirForTest(t, nested)
+
+	cfg := New("DISCOVERTEST")
+	path, err := cfg.Discover("App", "", ConfigTypeYAML)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if path != file {
+		t.Fatalf("expected %q, got %q", file, path)
+	}
+
+	app := new(envOverridesFileConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Address != "discovered" {
+		t.Fatalf("unexpected address %q", app.Address)
+	}
+}
+
+func TestDiscoverStopsAtVCSRoot(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	repo := filepath.Join(root, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o700); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".tool.yaml"), []byte("address: outside\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	chdirForTest(t, repo)
+
+	cfg := New("DISCOVERTEST2")
+	if _, err := cfg.Discover("app", "tool", ConfigTypeYAML); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error at the repository root, got %v", err)
+	}
+}
+
+func TestResolveDoesNotTouchFilesystem(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "not", "created")
+	cfg := New("RESOLVETEST")
+	if err := cfg.Resolve("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got := cfg.Path(); got != filepath.Join(dir, "config.yaml") {
+		t.Fatalf("unexpected path %q", got)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected Resolve not to create %q, got %v", dir, err)
+	}
+
+	err := cfg.ReadFile(new(envOverridesFileConfig))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected missing file to match fs.ErrNotExist, got %v", err)
+	}
+
+	if err := cfg.EnsureDir(); err != nil {
+		t.Fatalf("EnsureDir failed: %v", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() || info.Mode().Perm() != 0o700 {
+		t.Fatalf("expected directory with mode 0700, got %v, %v", info, err)
+	}
+}
+
+func TestMemMapFsBacksCreateAndReadFile(t *testing.T) {
+	mem := afero.NewMemMapFs()
+	cfg := New("MEMFSTEST")
+	cfg.SetFs(mem)
+	if err := cfg.SetHomeDir("/home/tester"); err != nil {
+		t.Fatalf("SetHomeDir failed: %v", err)
+	}
+	if err := cfg.Create("app", "config", "", ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if want := "/home/tester/.app/config.yaml"; cfg.Path() != want {
+		t.Fatalf("expected %q, got %q", want, cfg.Path())
+	}
+	if ok, err := afero.DirExists(mem, "/home/tester/.app"); err != nil || !ok {
+		t.Fatalf("expected directory in memory filesystem: %v, %v", ok, err)
+	}
+	if _, err := os.Stat("/home/tester/.app"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected nothing on the OS filesystem, got %v", err)
+	}
+
+	if err := afero.WriteFile(mem, cfg.Path(), []byte("address: in-memory\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	app := new(envOverridesFileConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Address != "in-memory" {
+		t.Fatalf("unexpected address %q", app.Address)
+	}
+}
+
+func TestReadOnlyFSServesEmbeddedFiles(t *testing.T) {
+	bundle := fstest.MapFS{
+		"etc/app/config.yaml":        {Data: []byte("address: embedded\n")},
+		"etc/app/config.d/10-a.yaml": {Data: []byte("address: drop-in\n")},
+	}
+
+	cfg := New("ROFSTEST")
+	cfg.SetReadOnlyFS(bundle)
+	if err := cfg.Resolve("app", "config", "/etc/app", ConfigTypeYAML); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if err := cfg.EnableDropIns(""); err != nil {
+		t.Fatalf("EnableDropIns failed: %v", err)
+	}
+
+	app := new(envOverridesFileConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Address != "drop-in" {
+		t.Fatalf("unexpected address %q", app.Address)
+	}
+	if err := cfg.EnsureDir(); err == nil {
+		t.Fatalf("expected EnsureDir to fail on a read-only filesystem")
+	}
+}
+
+func TestReadReaderMatchesReadFileStrictness(t *testing.T) {
+	cfg := New("READERTEST")
+	app := new(fileAppConfig)
+	content := `{"address": "from-stdin", "enabled": false, "features": {"enabled": true}}`
+	if err := cfg.ReadReader(strings.NewReader(content), ConfigTypeJSON, app); err != nil {
+		t.Fatalf("ReadReader failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if app.Address != "from-stdin" || app.Enabled || !app.Features.Enabled {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+
+	err := New("READERTEST").ReadReader(strings.NewReader("address: x\nunknown: y\n"), ConfigTypeYAML, new(fileAppConfig))
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+
+	err = New("READERTEST").ReadReader(strings.NewReader("address: [unterminated\n"), ConfigTypeYAML, new(fileAppConfig))
+	var parseErr viper.ConfigParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ConfigParseError, got %T: %v", err, err)
+	}
+}
+
+func TestReadReaderRejectedDocumentIsNotKept(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("READERTEST2")
+	if err := cfg.Create("app", "c", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte("address: from-file\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(fileAppConfig)
+	if err := cfg.ReadReader(strings.NewReader(`{"hots": "r"}`), ConfigTypeJSON, app); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile after rejected document failed: %v", err)
+	}
+	if app.Address != "from-file" {
+		t.Fatalf("unexpected address %q", app.Address)
+	}
+}
+
+type defaultsServer struct {
+	Name string `yaml:"name" mapstructure:"NAME"`
+	Port int    `yaml:"port" mapstructure:"PORT"`
+}
+
+type defaultsNestedConfig struct {
+	Enabled bool          `yaml:"enabled" mapstructure:"ENABLED" config:"required"`
+	Timeout time.Duration `yaml:"timeout" mapstructure:"TIMEOUT" config:"default=5s"`
+}
+
+type defaultsDocConfig struct {
+	Address  string               `yaml:"address" mapstructure:"ADDRESS" config:"required"`
+	Token    string               `yaml:"token" mapstructure:"TOKEN" config:"required"`
+	Servers  []defaultsServer     `yaml:"servers" mapstructure:"SERVERS"`
+	Features defaultsNestedConfig `yaml:"features" config:"struct,required"`
+}
+
+func TestReadDefaultsLayersBeneathFileWithoutPresence(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("DEFAULTSDOCTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("address: file-address\nfeatures:\n  timeout: 1m\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	defaults := strings.Join([]string{
+		"address: default-address",
+		"servers:",
+		"  - name: primary",
+		"    port: 5432",
+		"features:",
+		"  enabled: false",
+		"  timeout: 30s",
+	}, "\n")
+
+	app := new(defaultsDocConfig)
+	if err := cfg.ReadDefaults(strings.NewReader(defaults), ConfigTypeYAML, app); err != nil {
+		t.Fatalf("ReadDefaults failed: %v", err)
+	}
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+
+	err := cfg.Check(app)
+	if err == nil || !strings.Contains(err.Error(), "DEFAULTSDOCTEST_TOKEN") {
+		t.Fatalf("expected uncovered required token to fail, got %v", err)
+	}
+
+	t.Setenv("DEFAULTSDOCTEST_TOKEN", "secret")
+	if err := cfg.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	if app.Address != "file-address" {
+		t.Fatalf("expected file to override defaults document, got %q", app.Address)
+	}
+	if app.Features.Enabled || app.Features.Timeout != time.Minute {
+		t.Fatalf("unexpected nested values: %+v", app.Features)
+	}
+	if !reflect.DeepEqual(app.Servers, []defaultsServer{{Name: "primary", Port: 5432}}) {
+		t.Fatalf("unexpected servers: %+v", app.Servers)
+	}
+}
+
+func TestReadDefaultsRejectsUnknownKeys(t *testing.T) {
+	cfg := New("DEFAULTSDOCTEST2")
+	err := cfg.ReadDefaults(strings.NewReader("adress: typo\n"), ConfigTypeYAML, new(defaultsDocConfig))
+	if err == nil {
+		t.Fatalf("expected unknown key in defaults document to fail")
+	}
+}
+
+func TestWriteFileRoundTripsAndRefusesOverwrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "conf")
+	cfg := New("WRITETEST")
+	if err := cfg.Resolve("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	app := &defaultsDocConfig{
+		Address:  "0.0.0.0",
+		Token:    "secret",
+		Servers:  []defaultsServer{{Name: "primary", Port: 5432}},
+		Features: defaultsNestedConfig{Enabled: false, Timeout: 90 * time.Second},
+	}
+	if err := cfg.WriteFile(app); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	info, err := os.Stat(cfg.Path())
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected file with mode 0600, got %v, %v", info, err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("expected directory with mode 0700, got %v, %v", info, err)
+	}
+
+	readBack := new(defaultsDocConfig)
+	if err := New("WRITETEST").ReadReader(fileReader(t, cfg.Path()), ConfigTypeYAML, readBack); err != nil {
+		t.Fatalf("ReadReader failed: %v", err)
+	}
+	if !reflect.DeepEqual(readBack, app) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", readBack, app)
+	}
+
+	if err := cfg.WriteFile(app); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected WriteFile to refuse overwrite, got %v", err)
+	}
+
+	app.Address = "127.0.0.1"
+	if err := cfg.OverwriteFile(app); err != nil {
+		t.Fatalf("OverwriteFile failed: %v", err)
+	}
+	readBack = new(defaultsDocConfig)
+	reader := New("WRITETEST")
+	if err := reader.Resolve("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if err := reader.ReadFile(readBack); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if readBack.Address != "127.0.0.1" {
+		t.Fatalf("expected overwritten address, got %q", readBack.Address)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the config file in %q, got %v, %v", dir, entries, err)
+	}
+}
+
+func fileReader(t *testing.T, path string) io.Reader {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return bytes.NewReader(data)
+}
+
+// differingTagsConfig names its fields differently in codec tags than in
+// mapstructure tags, which decide the keys ReadFile accepts.
+type differingTagsConfig struct {
+	Address string           `yaml:"listen_addr" json:"listenAddr" mapstructure:"ADDRESS" config:"default=0.0.0.0"`
+	Log     differingTagsLog `yaml:"logging" mapstructure:"LOG" config:"struct"`
+}
+
+type differingTagsLog struct {
+	Level string `yaml:"lvl" mapstructure:"LEVEL" config:"desc=Log level,default=info"`
+}
+
+func TestWriteFileUsesKeysReadFileAccepts(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("WRITETEST2")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	app := &differingTagsConfig{Address: "127.0.0.1", Log: differingTagsLog{Level: "warn"}}
+	if err := cfg.WriteFile(app); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	readBack := new(differingTagsConfig)
+	if err := cfg.ReadFile(readBack); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !reflect.DeepEqual(readBack, app) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", readBack, app)
+	}
+}
+
+type templateNestedConfig struct {
+	Enabled bool          `yaml:"enabled" json:"enabled" mapstructure:"ENABLED" config:"desc=Turns the feature set on,default=true"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"TIMEOUT" config:"default=5s"`
+}
+
+type templateConfig struct {
+	Address  string               `yaml:"address" json:"address" mapstructure:"ADDRESS" config:"required,desc='Listen address, host or IP'"`
+	Port     int                  `yaml:"port" json:"port" mapstructure:"PORT" config:"desc=TCP port,default=8000"`
+	Origin   []string             `yaml:"origin" json:"origin" mapstructure:"ORIGIN" config:"default=localhost,127.0.0.1"`
+	Motd     string               `yaml:"motd" json:"motd" mapstructure:"MOTD"`
+	Features templateNestedConfig `yaml:"features" json:"features" config:"struct"`
+}
+
+func TestInitFileWritesCommentedYAMLTemplate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("INITTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := cfg.InitFile(&templateConfig{Motd: "line one\nline two"}); err != nil {
+		t.Fatalf("InitFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.Path())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{
+		"# Listen address, host or IP\n# required\n# address: \"\"\n",
+		"# TCP port\nport: 8000\n",
+		"origin:\n  - localhost\n  - 127.0.0.1\n",
+		"features:\n  # Turns the feature set on\n  enabled: true\n  timeout: 5s\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("template missing %q:\n%s", want, content)
+		}
+	}
+
+	app := new(templateConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile of template failed: %v\n%s", err, content)
+	}
+	if app.Port != 8000 || app.Motd != "line one\nline two" || !app.Features.Enabled || app.Features.Timeout != 5*time.Second {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+	if err := cfg.Check(app); err == nil || !strings.Contains(err.Error(), "INITTEST_ADDRESS") {
+		t.Fatalf("expected Check to ask for the address, got %v", err)
+	}
+
+	if err := cfg.InitFile(new(templateConfig)); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected InitFile to leave an existing file alone, got %v", err)
+	}
+}
+
+func TestInitFileWritesPlainJSONTemplate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("INITTEST2")
+	if err := cfg.Create("app", "config", dir, ConfigTypeJSON); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := cfg.InitFile(new(templateConfig)); err != nil {
+		t.Fatalf("InitFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.Path())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if strings.Contains(string(data), "comment") || strings.Contains(string(data), "address") {
+		t.Fatalf("expected JSON template without comments or unset required keys:\n%s", data)
+	}
+
+	app := new(templateConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile of template failed: %v\n%s", err, data)
+	}
+	if app.Port != 8000 || !reflect.DeepEqual(app.Origin, []string{"localhost", "127.0.0.1"}) {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+}
+
+func TestInitFileUsesKeysReadFileAccepts(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("INITTEST3")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := cfg.InitFile(new(differingTagsConfig)); err != nil {
+		t.Fatalf("InitFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.Path())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if want := "log:\n  # Log level\n  level: info\n"; !strings.Contains(string(data), want) {
+		t.Fatalf("template missing %q:\n%s", want, data)
+	}
+
+	app := new(differingTagsConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile of template failed: %v\n%s", err, data)
+	}
+	if app.Address != "0.0.0.0" || app.Log.Level != "info" {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+}
+
+type editServerConfig struct {
+	Port    int           `yaml:"port" mapstructure:"PORT"`
+	Timeout time.Duration `yaml:"timeout" mapstructure:"TIMEOUT"`
+}
+
+type editConfig struct {
+	Address string           `yaml:"address" mapstructure:"ADDRESS"`
+	Origin  []string         `yaml:"origin" mapstructure:"ORIGIN"`
+	Server  editServerConfig `yaml:"server" config:"struct"`
+}
+
+func TestSetKeyPreservesCommentsAndValidates(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("EDITTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	original := strings.Join([]string{
+		"# Main settings",
+		"address: 0.0.0.0 # listen everywhere",
+		"",
+		"# Server section",
+		"server:",
+		"  # port comment",
+		"  port: 8000",
+		"",
+	}, "\n")
+	if err := os.WriteFile(cfg.Path(), []byte(original), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := cfg.SetKey(new(editConfig), "server.port", "9000"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+	if err := cfg.SetKey(new(editConfig), "server.timeout", "1m"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+	if err := cfg.SetKey(new(editConfig), "ORIGIN", "a, b"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+	if err := cfg.SetKey(new(editConfig), "server.port", "not-a-number"); err == nil {
+		t.Fatalf("expected invalid value to be rejected")
+	}
+	if err := cfg.SetKey(new(editConfig), "server.prot", "1"); err == nil || !strings.Contains(err.Error(), "server.prot") {
+		t.Fatalf("expected unknown key to be rejected, got %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.Path())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{"# Main settings\n", "address: 0.0.0.0 # listen everywhere\n", "# Server section\n", "  # port comment\n  port: 9000\n", "  timeout: 1m0s\n"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("edited file missing %q:\n%s", want, content)
+		}
+	}
+
+	app := new(editConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v\n%s", err, content)
+	}
+	if app.Server.Port != 9000 || app.Server.Timeout != time.Minute || !reflect.DeepEqual(app.Origin, []string{"a", "b"}) {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+
+	if err := cfg.UnsetKey(new(editConfig), "server.port"); err != nil {
+		t.Fatalf("UnsetKey failed: %v", err)
+	}
+	if err := cfg.UnsetKey(new(editConfig), "server.port"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	data, err = os.ReadFile(cfg.Path())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if strings.Contains(string(data), "port:") || !strings.Contains(string(data), "timeout: 1m0s") {
+		t.Fatalf("unexpected file after unset:\n%s", data)
+	}
+}
+
+func TestSetKeyCreatesMissingFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "new")
+	cfg := New("EDITTEST2")
+	if err := cfg.Resolve("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if err := cfg.SetKey(new(editConfig), "server.port", "7000"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+
+	app := new(editConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Server.Port != 7000 {
+		t.Fatalf("unexpected port %d", app.Server.Port)
+	}
+}
+
+func TestSetKeyWritesKeysReadFileAccepts(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("EDITTEST3")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte("listen_addr: 0.0.0.0 # listen everywhere\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := cfg.SetKey(new(differingTagsConfig), "listen_addr", "127.0.0.1"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+	if err := cfg.SetKey(new(differingTagsConfig), "logging.lvl", "warn"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.Path())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{"address: 127.0.0.1 # listen everywhere\n", "log:\n  level: warn\n"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("edited file missing %q:\n%s", want, content)
+		}
+	}
+
+	app := new(differingTagsConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v\n%s", err, content)
+	}
+	if app.Address != "127.0.0.1" || app.Log.Level != "warn" {
+		t.Fatalf("unexpected values: %+v", app)
+	}
+}
+
+type checkAggregateNested struct {
+	Host    string        `mapstructure:"DB_HOST" config:"required"`
+	Timeout time.Duration `mapstructure:"DB_TIMEOUT" config:"default=forever"`
+}
+
+type checkAggregateConfig struct {
+	Address  string               `mapstructure:"ADDRESS" config:"required"`
+	Port     int                  `mapstructure:"PORT" config:"default=8000"`
+	Token    string               `mapstructure:"TOKEN" config:"required"`
+	Database checkAggregateNested `config:"struct"`
+}
+
+func TestCheckReportsEveryFailureWithFieldPath(t *testing.T) {
+	c := New("AGGTEST")
+	app := new(checkAggregateConfig)
+	if err := c.ReadEnv(app); err != nil {
+		t.Fatalf("ReadEnv failed: %v", err)
+	}
+
+	err := c.Check(app)
+	if err == nil {
+		t.Fatalf("expected Check to fail")
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("expected a joined error, got %T", err)
+	}
+	if got := len(joined.Unwrap()); got != 4 {
+		t.Fatalf("expected 4 failures, got %d: %v", got, err)
+	}
+	for _, want := range []string{`"AGGTEST_ADDRESS" for field "Address"`, `"AGGTEST_TOKEN" for field "Token"`, `"AGGTEST_DB_HOST" for field "Database.Host"`, `default: Database.Timeout: `} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got:\n%v", want, err)
+		}
+	}
+	if app.Port != 8000 {
+		t.Fatalf("expected defaults to be applied despite failures, got port %d", app.Port)
+	}
+}
+
+type typedErrorNested struct {
+	Port int `yaml:"port" mapstructure:"PORT"`
+}
+
+type typedErrorConfig struct {
+	Name    string           `yaml:"name" mapstructure:"NAME" config:"required"`
+	Workers int              `yaml:"workers" mapstructure:"WORKERS"`
+	Server  typedErrorNested `yaml:"server" config:"struct"`
+}
+
+type badTagConfig struct {
+	Name string `mapstructure:"NAME" config:"requird"`
+}
+
+func TestReadFileReturnsTypedErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	cfg := New("TYPEDTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	err := cfg.ReadFile(new(typedErrorConfig))
+	var notFound *FileNotFoundError
+	if !errors.As(err, &notFound) || !errors.Is(err, fs.ErrNotExist) || notFound.Paths[0] != path {
+		t.Fatalf("expected FileNotFoundError for %s, got %v", path, err)
+	}
+
+	if err := os.WriteFile(path, []byte("name: x\nserver:\n  port: [1\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	err = cfg.ReadFile(new(typedErrorConfig))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, ErrParse) || parseErr.File != path || parseErr.Line == 0 {
+		t.Fatalf("expected ParseError with a line, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("name: x\nnmae: y\nserver:\n  port: many\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	err = cfg.ReadFile(new(typedErrorConfig))
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) || !errors.Is(err, ErrUnknownKey) || errors.Is(err, ErrKeyNotFound) || unknown.Key != "nmae" || unknown.Source != path {
+		t.Fatalf("expected UnknownKeyError for nmae, got %v", err)
+	}
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || !errors.Is(err, ErrInvalidValue) || decodeErr.Key != "server.port" || decodeErr.Expected != "int" {
+		t.Fatalf("expected DecodeError for server.port, got %v", err)
+	}
+}
+
+func TestReadFileDecodeErrorKeepsValidValues(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("PARTIALTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte("address: a\nport: many\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(flagConfig)
+	if err := cfg.ReadFile(app); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected invalid value error, got %v", err)
+	}
+	if app.Address != "a" {
+		t.Fatalf("expected the valid value to be decoded, got %q", app.Address)
+	}
+	if err := cfg.Check(app); err != nil {
+		t.Fatalf("expected the decoded address to count as present, got %v", err)
+	}
+	if app.Port != 8000 {
+		t.Fatalf("expected the failed port to be left to its default, got %d", app.Port)
+	}
+}
+
+func TestReadJSONParseErrorHasLineAndColumn(t *testing.T) {
+	cfg := New("TYPEDTEST")
+	err := cfg.ReadReader(strings.NewReader("{\n  \"name\": \"x\",\n}"), ConfigTypeJSON, new(typedErrorConfig))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 || parseErr.Column != 2 {
+		t.Fatalf("expected ParseError at 3:2, got %v", err)
+	}
+}
+
+func TestReadEnvAndCheckReturnTypedErrors(t *testing.T) {
+	t.Setenv("TYPEDTEST_WORKERS", "many")
+	cfg := New("TYPEDTEST")
+	app := new(typedErrorConfig)
+	err := cfg.ReadEnv(app)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Source != "env" || decodeErr.Key != "TYPEDTEST_WORKERS" {
+		t.Fatalf("expected DecodeError for TYPEDTEST_WORKERS, got %v", err)
+	}
+
+	err = cfg.Check(app)
+	var missing *MissingFieldError
+	if !errors.As(err, &missing) || !errors.Is(err, ErrMissingField) {
+		t.Fatalf("expected MissingFieldError, got %v", err)
+	}
+	if missing.Field != "Name" || missing.EnvVar != "TYPEDTEST_NAME" || missing.Key != "name" {
+		t.Fatalf("unexpected MissingFieldError %+v", missing)
+	}
+
+	err = cfg.Check(new(badTagConfig))
+	var tagErr *TagError
+	if !errors.As(err, &tagErr) || !errors.Is(err, ErrInvalidTag) || tagErr.Field != "Name" || tagErr.Token != "requird" {
+		t.Fatalf("expected TagError for requird, got %v", err)
+	}
+}
+
+func TestDecodeErrorsCarryFilePositions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	cfg := New("POSTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	content := "name: x\nserver:\n  port: many\n  hots: a\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	err := cfg.ReadFile(new(typedErrorConfig))
+
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) || unknown.Line != 4 || unknown.Column != 3 {
+		t.Fatalf("expected unknown key at 4:3, got %v", err)
+	}
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Line != 3 || decodeErr.Column != 9 {
+		t.Fatalf("expected decode error at 3:9, got %v", err)
+	}
+	if !strings.Contains(err.Error(), path+`:4:3: unknown key "server.hots"`) {
+		t.Fatalf("expected file:line:col in message, got %v", err)
+	}
+}
+
+func TestDecodeErrorsDoNotBlameFilesForOtherSources(t *testing.T) {
+	dir := t.TempDir()
+	cfg := New("POSTEST2")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte("address: a\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	dotenv := filepath.Join(dir, ".env")
+	if err := os.WriteFile(dotenv, []byte("POSTEST2_PORT=many\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	app := new(flagConfig)
+	if err := cfg.ReadDotEnv(dotenv, app); err == nil {
+		t.Fatalf("expected invalid dotenv value to fail")
+	}
+	err := cfg.ReadFile(app)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Key != "port" || decodeErr.Source != "" {
+		t.Fatalf("expected decode error for port without a file source, got %#v", err)
+	}
+	if strings.Contains(err.Error(), cfg.Path()) {
+		t.Fatalf("expected the file not to be blamed, got %v", err)
+	}
+}
+
+func TestJSONDecodeErrorsCarryPositions(t *testing.T) {
+	cfg := New("POSTEST")
+	err := cfg.ReadReader(strings.NewReader("{\n  \"name\": \"x\",\n  \"server\": {\"prot\": 1}\n}\n"), ConfigTypeJSON, new(typedErrorConfig))
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) || unknown.Key != "server.prot" || unknown.Line != 3 || unknown.Column != 14 {
+		t.Fatalf("expected unknown key at 3:14, got %v", err)
+	}
+}
+
+type positionRequiredNested struct {
+	Host string `yaml:"host" mapstructure:"HOST" config:"required"`
+	Name string `yaml:"name" mapstructure:"NAME"`
+}
+
+type positionRequiredConfig struct {
+	Database positionRequiredNested `yaml:"database" config:"struct"`
+}
+
+func TestMissingFieldPointsAtEnclosingSection(t *testing.T) {
+	cfg := New("POSTEST")
+	app := new(positionRequiredConfig)
+	if err := cfg.ReadReader(strings.NewReader("# settings\ndatabase:\n  name: app\n"), ConfigTypeYAML, app); err != nil {
+		t.Fatalf("ReadReader failed: %v", err)
+	}
+
+	err := cfg.Check(app)
+	var missing *MissingFieldError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingFieldError, got %v", err)
+	}
+	if missing.Key != "database.host" || missing.File != "yaml input" || missing.Line != 2 || missing.Column != 1 {
+		t.Fatalf("unexpected MissingFieldError %+v", missing)
+	}
+}
+
+func TestSuggestRanksByEditDistance(t *testing.T) {
+	candidates := []string{"address", "port", "origin", "timeout"}
+	if got := Suggest("adress", candidates); len(got) != 1 || got[0] != "address" {
+		t.Fatalf("expected address, got %v", got)
+	}
+	if got := Suggest("PROT", candidates); len(got) != 1 || got[0] != "port" {
+		t.Fatalf("expected transposition to match port, got %v", got)
+	}
+	if got := Suggest("verbose", candidates); len(got) != 0 {
+		t.Fatalf("expected no suggestions, got %v", got)
+	}
+}
+
+func TestUnknownKeysSuggestNestedKeys(t *testing.T) {
+	cfg := New("SUGGESTTEST")
+	err := cfg.ReadReader(strings.NewReader("nmae: x\nserver:\n  prot: 1\n"), ConfigTypeYAML, new(typedErrorConfig))
+
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected UnknownKeyError, got %v", err)
+	}
+	for _, want := range []string{`unknown key "nmae", did you mean "name"?`, `unknown key "server.prot", did you mean "server.port"?`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestUnknownEnvSuggestsVariables(t *testing.T) {
+	t.Setenv("SUGGESTTEST_WORKRES", "4")
+	t.Setenv("SUGGESTTEST_NAME", "x")
+	t.Setenv("SUGGESTTEST_CONFIG", "/etc/app.yaml")
+	cfg := New("SUGGESTTEST")
+
+	err := cfg.UnknownEnv(new(typedErrorConfig))
+	var unknown *UnknownKeyError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected UnknownKeyError, got %v", err)
+	}
+	if unknown.Key != "SUGGESTTEST_WORKRES" || len(unknown.Suggestions) != 1 || unknown.Suggestions[0] != "SUGGESTTEST_WORKERS" {
+		t.Fatalf("unexpected UnknownKeyError %+v", unknown)
+	}
+	if strings.Count(err.Error(), "unknown key") != 1 {
+		t.Fatalf("expected only SUGGESTTEST_WORKRES to be reported, got:\n%v", err)
+	}
+}
+
+type rereadConfig struct {
+	Port int    `yaml:"port" mapstructure:"PORT"`
+	Host string `yaml:"host" mapstructure:"HOST" config:"required"`
+}
+
+func TestReadFileAgainDropsRemovedKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	cfg := New("REREADTEST")
+	if err := cfg.Create("app", "config", dir, ConfigTypeYAML); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := cfg.ReadReader(strings.NewReader("port: 7\n"), ConfigTypeYAML, new(rereadConfig)); err != nil {
+		t.Fatalf("ReadReader failed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("port: 1\nhost: a\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := cfg.ReadFile(new(rereadConfig)); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("port: 2\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	app := new(rereadConfig)
+	if err := cfg.ReadFile(app); err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if app.Host != "" {
+		t.Fatalf("expected host removed from the file to be unset, got %q", app.Host)
+	}
+	if app.Port != 7 {
+		t.Fatalf("expected the ReadReader document to stay above the file, got port %d", app.Port)
+	}
+	if err := cfg.Check(app); !errors.Is(err, ErrMissingField) {
+		t.Fatalf("expected Check to report the removed required host, got %v", err)
+	}
+	if len(cfg.layers) != 1 {
+		t.Fatalf("expected one file layer after re-reading, got %d", len(cfg.layers))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// loadSchema type-checks the package at pattern, relative to dir, and
+// returns the schema of its struct type typeName.
+func loadSchema(dir, pattern, typeName string) (*Schema, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedImports,
+		Dir:  dir,
+	}
+
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", pattern, err)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("loading %s: expected one package, found %d", pattern, len(pkgs))
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, fmt.Errorf("loading %s: %v", pattern, pkg.Errors[0])
+	}
+
+	obj := pkg.Types.Scope().Lookup(typeName)
+	if obj == nil {
+		return nil, fmt.Errorf("type %s not found in %s", typeName, pkg.PkgPath)
+	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return nil, fmt.Errorf("%s.%s is not a type", pkg.PkgPath, typeName)
+	}
+	if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+		return nil, fmt.Errorf("%s.%s is not a struct type", pkg.PkgPath, typeName)
+	}
+
+	ts, err := schemaFromType(obj.Type())
+	if err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", pkg.PkgPath, typeName, err)
+	}
+	return &Schema{Name: pkg.PkgPath + "." + typeName, Type: ts}, nil
+}
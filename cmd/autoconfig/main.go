@@ -0,0 +1,287 @@
+// Command autoconfig validates config files against an autoconfig struct
+// without building the program that owns it.
+//
+// The struct comes from a Go package, type-checked with go/packages, or from
+// a schema generated earlier with -gen-schema:
+//
+//	autoconfig -pkg ./internal/config -type AppConfig config.yaml
+//	autoconfig -pkg ./internal/config -type AppConfig -gen-schema > schema.json
+//	autoconfig -schema schema.json config.yaml other.toml
+//
+// Every file is strictly decoded as by ReadFile and checked as by Check, and
+// the problems of both steps are reported together. Problems are printed one
+// per line in file order, prefixed with file:line:col where the position is
+// known, and the exit code is 1 when any file has problems and 2 for usage or
+// loading errors.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/handletec/autoconfig"
+)
+
+const (
+	exitOK       = 0
+	exitProblems = 1
+	exitUsage    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("autoconfig", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	pkg := fs.String("pkg", "", "Go package `pattern` holding the config struct")
+	typeName := fs.String("type", "", "`name` of the config struct type in -pkg")
+	schemaPath := fs.String("schema", "", "schema `file` generated with -gen-schema, instead of -pkg and -type")
+	genSchema := fs.Bool("gen-schema", false, "print the schema of -pkg and -type as JSON and exit")
+	prefix := fs.String("prefix", "", "environment variable `prefix` used in messages and by -env")
+	useEnv := fs.Bool("env", false, "also apply environment variables before checking")
+	dir := fs.String("C", ".", "run go/packages in `dir`")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: autoconfig (-pkg pattern -type name | -schema file) [flags] file...")
+		fmt.Fprintln(stderr, "       autoconfig -pkg pattern -type name -gen-schema")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	schema, err := loadRequestedSchema(*dir, *pkg, *typeName, *schemaPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "autoconfig: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+
+	if *genSchema {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(schema); err != nil {
+			fmt.Fprintf(stderr, "autoconfig: %v\n", err)
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "autoconfig: no config files given")
+		fs.Usage()
+		return exitUsage
+	}
+
+	rt, err := schema.Type.reflectType()
+	if err != nil {
+		fmt.Fprintf(stderr, "autoconfig: schema %s: %v\n", schema.Name, err)
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, file := range fs.Args() {
+		problems, err := validateFile(rt, file, *prefix, *useEnv)
+		if err != nil {
+			fmt.Fprintf(stderr, "autoconfig: %v\n", err)
+			return exitUsage
+		}
+		sort.SliceStable(problems, func(i, j int) bool {
+			return problems[i].before(problems[j])
+		})
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "%s: %s\n", p.position(file), p.message)
+		}
+		if len(problems) > 0 {
+			code = exitProblems
+		}
+	}
+	return code
+}
+
+var errUsage = errors.New("either -schema or both -pkg and -type are required")
+
+func loadRequestedSchema(dir, pkg, typeName, schemaPath string) (*Schema, error) {
+	switch {
+	case schemaPath != "" && pkg == "" && typeName == "":
+		data, err := os.ReadFile(schemaPath)
+		if err != nil {
+			return nil, err
+		}
+		schema := new(Schema)
+		if err := json.Unmarshal(data, schema); err != nil {
+			return nil, fmt.Errorf("reading schema %s: %w", schemaPath, err)
+		}
+		if schema.Type == nil || schema.Type.Kind != "struct" {
+			return nil, fmt.Errorf("schema %s does not describe a struct", schemaPath)
+		}
+		return schema, nil
+	case schemaPath == "" && pkg != "" && typeName != "":
+		return loadSchema(dir, pkg, typeName)
+	default:
+		return nil, errUsage
+	}
+}
+
+// problem is one finding in a config file. line and col are zero when the
+// position is unknown.
+type problem struct {
+	line, col int
+	message   string
+}
+
+// before orders problems by position, those without one last.
+func (p problem) before(q problem) bool {
+	switch {
+	case p.line <= 0 || q.line <= 0:
+		return p.line > 0 && q.line <= 0
+	case p.line != q.line:
+		return p.line < q.line
+	default:
+		return p.col < q.col
+	}
+}
+
+// position renders file:line:col for editors and CI annotations.
+func (p problem) position(file string) string {
+	switch {
+	case p.line <= 0:
+		return file
+	case p.col <= 0:
+		return fmt.Sprintf("%s:%d", file, p.line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", file, p.line, p.col)
+	}
+}
+
+// validateFile decodes one file into a new value of rt and checks it. It
+// returns the problems found; err is reserved for files that cannot be
+// validated at all.
+func validateFile(rt reflect.Type, file, prefix string, useEnv bool) ([]problem, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := autoconfig.New(prefix)
+	if err := cfg.SetFileSources(autoconfig.FileSource{Path: path}); err != nil {
+		return nil, err
+	}
+
+	// Decode problems leave the values that did decode in s, so the file is
+	// still checked and every problem is reported in one run. Only a file
+	// that cannot be parsed stops here.
+	var problems []problem
+	failed := make(map[string]struct{})
+	s := reflect.New(rt).Interface()
+	if err := cfg.ReadFile(s); err != nil {
+		var parseErr *autoconfig.ParseError
+		if errors.As(err, &parseErr) || errors.Is(err, fs.ErrNotExist) {
+			return splitProblems(err), nil
+		}
+		problems = splitProblems(err)
+		for _, e := range unjoin(err) {
+			var decode *autoconfig.DecodeError
+			if errors.As(e, &decode) {
+				key, _, _ := strings.Cut(decode.Key, "[")
+				failed[key] = struct{}{}
+			}
+		}
+	}
+
+	if useEnv {
+		if err := cfg.ReadEnv(s); err != nil {
+			problems = append(problems, splitProblems(err)...)
+		}
+		if err := cfg.UnknownEnv(s); err != nil {
+			problems = append(problems, splitProblems(err)...)
+		}
+	}
+
+	// A required field whose value failed to decode is already reported.
+	if err := cfg.Check(s); err != nil {
+		for _, e := range unjoin(err) {
+			var missing *autoconfig.MissingFieldError
+			if errors.As(e, &missing) {
+				if _, ok := failed[missing.Key]; ok {
+					continue
+				}
+			}
+			problems = append(problems, splitProblems(e)...)
+		}
+	}
+	return problems, nil
+}
+
+// unjoin returns the errors combined with errors.Join in err, or err alone.
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// splitProblems turns an error into one problem per finding, without the
+// file name and operation prefixes the caller prints itself.
+func splitProblems(err error) []problem {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var problems []problem
+		for _, e := range joined.Unwrap() {
+			problems = append(problems, splitProblems(e)...)
+		}
+		return problems
+	}
+
+	var (
+		unknown *autoconfig.UnknownKeyError
+		decode  *autoconfig.DecodeError
+		missing *autoconfig.MissingFieldError
+		parse   *autoconfig.ParseError
+	)
+	switch {
+	case errors.As(err, &unknown):
+		msg := fmt.Sprintf("unknown key %q", unknown.Key)
+		if unknown.Source == "env" {
+			msg = fmt.Sprintf("unknown environment variable %q", unknown.Key)
+		}
+		if len(unknown.Suggestions) > 0 {
+			quoted := make([]string, len(unknown.Suggestions))
+			for i, s := range unknown.Suggestions {
+				quoted[i] = strconv.Quote(s)
+			}
+			msg += ", did you mean " + strings.Join(quoted, " or ") + "?"
+		}
+		return []problem{{unknown.Line, unknown.Column, msg}}
+	case errors.As(err, &decode):
+		return []problem{{decode.Line, decode.Column, fmt.Sprintf("%s: %v", decode.Key, decode.Err)}}
+	case errors.As(err, &missing):
+		// The caller prints the position, so leave it out of the message.
+		msg := *missing
+		msg.File = ""
+		return []problem{{missing.Line, missing.Column, msg.Error()}}
+	case errors.As(err, &parse):
+		return []problem{{parse.Line, parse.Column, parse.Err.Error()}}
+	}
+
+	var problems []problem
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			problems = append(problems, problem{message: line})
+		}
+	}
+	return problems
+}
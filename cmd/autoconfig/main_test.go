@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestValidatesFilesAgainstPackageType(t *testing.T) {
+	dir := t.TempDir()
+	good := writeFile(t, dir, "good.yaml", "address: 0.0.0.0\nlabels:\n  team: core\nlimits:\n  burst: 5\n")
+	bad := writeFile(t, dir, "bad.yaml", "address: 0.0.0.0\nprot: 1\nport: many\nlimits:\n  burst: 5\n")
+	missing := writeFile(t, dir, "missing.toml", "address = \"0.0.0.0\"\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-pkg", "./testdata/app", "-type", "Config", good}, &stdout, &stderr)
+	if code != exitOK || stdout.Len() != 0 {
+		t.Fatalf("expected clean run, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	code = run([]string{"-pkg", "./testdata/app", "-type", "Config", bad, missing}, &stdout, &stderr)
+	if code != exitProblems {
+		t.Fatalf("expected exit %d, got %d: %s", exitProblems, code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{bad + `:2:1: unknown key "prot", did you mean "port"?`, bad + ":3:7: port: ", missing + `: missing required "BURST"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestReportsDecodeAndCheckProblemsTogether(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "typo.yaml", "adress: x\nlimits:\n  burst: many\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-pkg", "./testdata/app", "-type", "Config", "-prefix", "APP", file}, &stdout, &stderr)
+	if code != exitProblems {
+		t.Fatalf("expected exit %d, got %d: %s", exitProblems, code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		file + `:1:1: unknown key "adress", did you mean "address"?`,
+		file + ":3:10: limits.burst: ",
+		`missing required "APP_ADDRESS" for field "Address"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "APP_BURST") {
+		t.Fatalf("expected the burst decode error alone, got:\n%s", out)
+	}
+}
+
+func TestGeneratedSchemaValidatesWithoutSources(t *testing.T) {
+	dir := t.TempDir()
+
+	var schema, stderr bytes.Buffer
+	if code := run([]string{"-pkg", "./testdata/app", "-type", "Config", "-gen-schema"}, &schema, &stderr); code != exitOK {
+		t.Fatalf("gen-schema failed with %d: %s", code, stderr.String())
+	}
+	if strings.Contains(schema.String(), "private") {
+		t.Fatalf("schema must not contain unexported fields:\n%s", schema.String())
+	}
+	schemaPath := writeFile(t, dir, "schema.json", schema.String())
+
+	good := writeFile(t, dir, "good.json", `{"address": "x", "limits": {"burst": 1, "window": "30s"}}`)
+	bad := writeFile(t, dir, "bad.json", `{"address": "x", "limits": {"burst": 1, "windw": "30s"}}`)
+
+	var stdout bytes.Buffer
+	if code := run([]string{"-schema", schemaPath, good}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected clean run, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	if code := run([]string{"-schema", schemaPath, bad}, &stdout, &stderr); code != exitProblems || !strings.Contains(stdout.String(), "windw") {
+		t.Fatalf("expected unknown key problem, got %d: %s", code, stdout.String())
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"config.yaml"}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("expected usage exit code, got %d", code)
+	}
+	if code := run([]string{"-pkg", "./testdata/app", "-type", "Missing", "config.yaml"}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("expected usage exit code for unknown type, got %d", code)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"time"
+)
+
+// Schema describes a config struct independently of its Go package, so that
+// it can be generated once and used to validate files without the sources.
+type Schema struct {
+	// Name is the Go type name, used in messages.
+	Name string `json:"name"`
+	// Type is the struct type itself.
+	Type *TypeSchema `json:"type"`
+}
+
+// TypeSchema describes one Go type.
+type TypeSchema struct {
+	// Kind is a reflect.Kind name such as "int" or "struct", or one of
+	// "duration", "time" and "any".
+	Kind   string         `json:"kind"`
+	Elem   *TypeSchema    `json:"elem,omitempty"`
+	Key    *TypeSchema    `json:"key,omitempty"`
+	Len    int            `json:"len,omitempty"`
+	Fields []*FieldSchema `json:"fields,omitempty"`
+}
+
+// FieldSchema describes one exported struct field with its raw tag.
+type FieldSchema struct {
+	Name string      `json:"name"`
+	Tag  string      `json:"tag,omitempty"`
+	Type *TypeSchema `json:"type"`
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	anyType      = reflect.TypeOf((*any)(nil)).Elem()
+
+	basicKinds = map[string]reflect.Type{
+		"bool":    reflect.TypeOf(false),
+		"string":  reflect.TypeOf(""),
+		"int":     reflect.TypeOf(int(0)),
+		"int8":    reflect.TypeOf(int8(0)),
+		"int16":   reflect.TypeOf(int16(0)),
+		"int32":   reflect.TypeOf(int32(0)),
+		"int64":   reflect.TypeOf(int64(0)),
+		"uint":    reflect.TypeOf(uint(0)),
+		"uint8":   reflect.TypeOf(uint8(0)),
+		"uint16":  reflect.TypeOf(uint16(0)),
+		"uint32":  reflect.TypeOf(uint32(0)),
+		"uint64":  reflect.TypeOf(uint64(0)),
+		"float32": reflect.TypeOf(float32(0)),
+		"float64": reflect.TypeOf(float64(0)),
+	}
+)
+
+// schemaFromType converts a type-checked struct into a schema.
+func schemaFromType(t types.Type) (*TypeSchema, error) {
+	return convertType(t, make(map[types.Type]bool))
+}
+
+func convertType(t types.Type, visiting map[types.Type]bool) (*TypeSchema, error) {
+	if named, ok := t.(*types.Named); ok {
+		if obj := named.Obj(); obj.Pkg() != nil && obj.Pkg().Path() == "time" {
+			switch obj.Name() {
+			case "Duration":
+				return &TypeSchema{Kind: "duration"}, nil
+			case "Time":
+				return &TypeSchema{Kind: "time"}, nil
+			}
+		}
+		if visiting[named] {
+			return nil, fmt.Errorf("recursive type %s is not supported", named)
+		}
+		visiting[named] = true
+		defer delete(visiting, named)
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		name := u.Name()
+		if u.Kind() == types.UntypedNil || basicKinds[name] == nil {
+			return nil, fmt.Errorf("unsupported basic type %s", name)
+		}
+		return &TypeSchema{Kind: name}, nil
+
+	case *types.Pointer:
+		elem, err := convertType(u.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &TypeSchema{Kind: "ptr", Elem: elem}, nil
+
+	case *types.Slice:
+		elem, err := convertType(u.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &TypeSchema{Kind: "slice", Elem: elem}, nil
+
+	case *types.Array:
+		elem, err := convertType(u.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &TypeSchema{Kind: "array", Elem: elem, Len: int(u.Len())}, nil
+
+	case *types.Map:
+		key, err := convertType(u.Key(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := convertType(u.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &TypeSchema{Kind: "map", Key: key, Elem: elem}, nil
+
+	case *types.Interface:
+		return &TypeSchema{Kind: "any"}, nil
+
+	case *types.Struct:
+		ts := &TypeSchema{Kind: "struct"}
+		for i := 0; i < u.NumFields(); i++ {
+			field := u.Field(i)
+			if !field.Exported() {
+				continue
+			}
+			ft, err := convertType(field.Type(), visiting)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name(), err)
+			}
+			ts.Fields = append(ts.Fields, &FieldSchema{Name: field.Name(), Tag: u.Tag(i), Type: ft})
+		}
+		return ts, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// reflectType builds a reflect.Type with the same shape and tags as the
+// schema, so that the autoconfig package can decode into it.
+func (ts *TypeSchema) reflectType() (reflect.Type, error) {
+	if ts == nil {
+		return nil, fmt.Errorf("missing type")
+	}
+
+	if rt, ok := basicKinds[ts.Kind]; ok {
+		return rt, nil
+	}
+
+	switch ts.Kind {
+	case "duration":
+		return durationType, nil
+	case "time":
+		return timeType, nil
+	case "any":
+		return anyType, nil
+	case "ptr", "slice", "array":
+		elem, err := ts.Elem.reflectType()
+		if err != nil {
+			return nil, err
+		}
+		switch ts.Kind {
+		case "ptr":
+			return reflect.PointerTo(elem), nil
+		case "slice":
+			return reflect.SliceOf(elem), nil
+		default:
+			return reflect.ArrayOf(ts.Len, elem), nil
+		}
+	case "map":
+		key, err := ts.Key.reflectType()
+		if err != nil {
+			return nil, err
+		}
+		elem, err := ts.Elem.reflectType()
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(key, elem), nil
+	case "struct":
+		fields := make([]reflect.StructField, 0, len(ts.Fields))
+		for _, f := range ts.Fields {
+			ft, err := f.Type.reflectType()
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			fields = append(fields, reflect.StructField{Name: f.Name, Type: ft, Tag: reflect.StructTag(f.Tag)})
+		}
+		return reflect.StructOf(fields), nil
+	default:
+		return nil, fmt.Errorf("unknown kind %q", ts.Kind)
+	}
+}
@@ -0,0 +1,18 @@
+// Package app holds a config struct for the autoconfig command tests.
+package app
+
+import "time"
+
+type Limits struct {
+	Burst   int           `yaml:"burst" mapstructure:"BURST" config:"required"`
+	Window  time.Duration `yaml:"window" mapstructure:"WINDOW" config:"default=1m"`
+	private string
+}
+
+type Config struct {
+	Address string            `yaml:"address" mapstructure:"ADDRESS" config:"required"`
+	Port    int               `yaml:"port" mapstructure:"PORT" config:"default=8000"`
+	Origin  []string          `yaml:"origin" mapstructure:"ORIGIN"`
+	Labels  map[string]string `yaml:"labels" mapstructure:"LABELS"`
+	Limits  Limits            `yaml:"limits" config:"struct"`
+}
@@ -0,0 +1,230 @@
+package autoconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"github.com/spf13/viper"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// Codec converts between a configuration file format and a settings map.
+type Codec interface {
+	// Decode parses data into a settings map.
+	Decode(data []byte) (map[string]any, error)
+	// Encode renders a settings map in the codec's format.
+	Encode(settings map[string]any) ([]byte, error)
+	// Extensions lists the file extensions, without the leading dot, handled
+	// by the codec. The first entry is used when building file paths.
+	Extensions() []string
+	// TagName is the struct tag consulted for presence tracking, such as "yaml".
+	TagName() string
+}
+
+type codecEntry struct {
+	name  string
+	codec Codec
+}
+
+// codecs is indexed by ConfigType. Index zero is ConfigTypeNone.
+var codecs = struct {
+	mu      sync.RWMutex
+	entries []codecEntry
+}{
+	entries: []codecEntry{
+		ConfigTypeNone:       {},
+		ConfigTypeYAML:       {name: "yaml", codec: yamlCodec{}},
+		ConfigTypeJSON:       {name: "json", codec: jsonCodec{}},
+		ConfigTypeTOML:       {name: "toml", codec: tomlCodec{}},
+		ConfigTypeINI:        {name: "ini", codec: iniCodec{}},
+		ConfigTypeProperties: {name: "properties", codec: propertiesCodec{}},
+	},
+}
+
+// RegisterCodec adds a file format and returns the ConfigType that selects it.
+// It is meant to be called during program initialisation, before any Config
+// reads files.
+func RegisterCodec(name string, codec Codec) (ConfigType, error) {
+	name = strings.TrimSpace(strings.ToLower(name))
+	if name == "" {
+		return ConfigTypeNone, fmt.Errorf("register codec: empty name")
+	}
+	if codec == nil {
+		return ConfigTypeNone, fmt.Errorf("register codec %q: nil codec", name)
+	}
+	if len(codec.Extensions()) == 0 {
+		return ConfigTypeNone, fmt.Errorf("register codec %q: no file extensions", name)
+	}
+
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+
+	for _, entry := range codecs.entries {
+		if entry.name == name {
+			return ConfigTypeNone, fmt.Errorf("register codec %q: already registered", name)
+		}
+	}
+	if len(codecs.entries) > int(^ConfigType(0)) {
+		return ConfigTypeNone, fmt.Errorf("register codec %q: too many codecs", name)
+	}
+
+	// Viper refuses to decode formats it does not list, even when its registry
+	// can resolve them.
+	if !slices.Contains(viper.SupportedExts, name) {
+		viper.SupportedExts = append(viper.SupportedExts, name)
+	}
+
+	codecs.entries = append(codecs.entries, codecEntry{name: name, codec: codec})
+	return ConfigType(len(codecs.entries) - 1), nil
+}
+
+func lookupCodec(ct ConfigType) (codecEntry, bool) {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+
+	if ct == ConfigTypeNone || int(ct) >= len(codecs.entries) {
+		return codecEntry{}, false
+	}
+	return codecs.entries[ct], true
+}
+
+func lookupCodecByName(name string) (codecEntry, bool) {
+	name = strings.ToLower(name)
+
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+
+	for _, entry := range codecs.entries[1:] {
+		if entry.name == name {
+			return entry, true
+		}
+	}
+	return codecEntry{}, false
+}
+
+// configTypeForExtension returns the ConfigType whose codec handles ext.
+func configTypeForExtension(ext string) (ConfigType, bool) {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+
+	for i, entry := range codecs.entries[1:] {
+		if slices.Contains(entry.codec.Extensions(), ext) {
+			return ConfigType(i + 1), true
+		}
+	}
+	return ConfigTypeNone, false
+}
+
+// codecTagNames returns the tag names of all registered codecs, in
+// registration order and without duplicates.
+func codecTagNames() []string {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+
+	names := make([]string, 0, len(codecs.entries))
+	for _, entry := range codecs.entries[1:] {
+		tag := entry.codec.TagName()
+		if tag == "" || slices.Contains(names, tag) {
+			continue
+		}
+		names = append(names, tag)
+	}
+	return names
+}
+
+// codecRegistry exposes the registered codecs to Viper.
+type codecRegistry struct{}
+
+func (codecRegistry) Encoder(format string) (viper.Encoder, error) {
+	entry, ok := lookupCodecByName(format)
+	if !ok {
+		return nil, errors.New("encoder not found for this format")
+	}
+	return viperCodec{entry.codec}, nil
+}
+
+func (codecRegistry) Decoder(format string) (viper.Decoder, error) {
+	entry, ok := lookupCodecByName(format)
+	if !ok {
+		return nil, errors.New("decoder not found for this format")
+	}
+	return viperCodec{entry.codec}, nil
+}
+
+// viperCodec adapts a Codec to Viper's encoder and decoder interfaces.
+type viperCodec struct {
+	codec Codec
+}
+
+func (vc viperCodec) Encode(v map[string]any) ([]byte, error) {
+	return vc.codec.Encode(v)
+}
+
+func (vc viperCodec) Decode(b []byte, v map[string]any) error {
+	settings, err := vc.codec.Decode(b)
+	if err != nil {
+		return err
+	}
+	for key, value := range settings {
+		v[key] = value
+	}
+	return nil
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Decode(data []byte) (map[string]any, error) {
+	settings := make(map[string]any)
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (yamlCodec) Encode(settings map[string]any) ([]byte, error) {
+	return yaml.Marshal(settings)
+}
+
+func (yamlCodec) Extensions() []string { return []string{"yaml", "yml"} }
+func (yamlCodec) TagName() string      { return tagYAML }
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(data []byte) (map[string]any, error) {
+	settings := make(map[string]any)
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (jsonCodec) Encode(settings map[string]any) ([]byte, error) {
+	return json.MarshalIndent(settings, "", "  ")
+}
+
+func (jsonCodec) Extensions() []string { return []string{"json"} }
+func (jsonCodec) TagName() string      { return tagJSON }
+
+type tomlCodec struct{}
+
+func (tomlCodec) Decode(data []byte) (map[string]any, error) {
+	settings := make(map[string]any)
+	if err := toml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (tomlCodec) Encode(settings map[string]any) ([]byte, error) {
+	return toml.Marshal(settings)
+}
+
+func (tomlCodec) Extensions() []string { return []string{"toml"} }
+func (tomlCodec) TagName() string      { return tagTOML }
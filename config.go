@@ -3,7 +3,8 @@ package autoconfig
 import (
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	mapstructure "github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 )
 
@@ -21,6 +23,9 @@ const (
 	tagMapStructure = "mapstructure"
 	tagJSON         = "json"
 	tagYAML         = "yaml"
+	tagTOML         = "toml"
+	tagINI          = "ini"
+	tagProperties   = "properties"
 )
 
 var (
@@ -36,10 +41,27 @@ type Config struct {
 	cfgBaseName string
 	cfgType     ConfigType
 	envPrefix   string
+	cfgEnv      string
+	fs          afero.Fs
+	home        string
+	cfgPath     string
+	location    Location
+	searchDirs  []string
+	legacyPath  string
+	sources     []FileSource
+	dropInDir   string
+	fileEnv     bool
+	loaded      []string
+	layers      []loadedSource
+	extraLayers []loadedSource
+	pflags      []*boundPFlag
 	v           *viper.Viper
 
 	structFields map[reflect.Type][]fieldMeta
 	present      map[string]struct{}
+	filePresent  map[string]struct{}
+	defaulted    map[string]struct{}
+	flagKeys     map[string]struct{}
 	mu           sync.RWMutex
 }
 
@@ -48,30 +70,56 @@ type fieldMeta struct {
 	name       string
 	index      []int
 	mapTag     string
-	yamlTag    string
-	jsonTag    string
+	tags       reflect.StructTag
 	required   bool
 	defaultVal *string
 	isStruct   bool
+	fileEnv    bool
+	desc       string
 }
 
 // New creates a new isolated Config instance.
 func New(prefix string) *Config {
-	v := viper.New()
+	v := viper.NewWithOptions(viper.WithCodecRegistry(codecRegistry{}))
+	// A bare CONFIG variable is too common to claim without a prefix.
+	cfgEnv := ""
 	if prefix != "" {
 		v.SetEnvPrefix(prefix)
+		cfgEnv = strings.ToUpper(envFieldName(prefix, configEnvKey))
 	}
 
 	return &Config{
 		envPrefix:    prefix,
+		cfgEnv:       cfgEnv,
+		fs:           afero.NewOsFs(),
 		v:            v,
 		structFields: make(map[reflect.Type][]fieldMeta),
 		present:      make(map[string]struct{}),
+		filePresent:  make(map[string]struct{}),
+		defaulted:    make(map[string]struct{}),
+		flagKeys:     make(map[string]struct{}),
 	}
 }
 
-// Create sets up config directory and Viper file settings.
+// Create resolves the config file path and sets up its directory. It is
+// Resolve followed by EnsureDir.
 func (c *Config) Create(project, baseCfgName, baseDirname string, cfgType ConfigType) error {
+	if err := c.Resolve(project, baseCfgName, baseDirname, cfgType); err != nil {
+		return err
+	}
+	return c.EnsureDir()
+}
+
+// Resolve resolves the config file path without touching the filesystem, so
+// that read-only services can call ReadFile and treat a missing file as a
+// normal state. An empty baseDirname selects a directory below the home
+// directory according to SetLocation.
+//
+// When the environment variable named by SetConfigEnv (<PREFIX>_CONFIG by
+// default when a prefix is set) is set, its value replaces the resolved path. It must be an
+// absolute path, and the file type is inferred from its extension instead of
+// cfgType.
+func (c *Config) Resolve(project, baseCfgName, baseDirname string, cfgType ConfigType) error {
 	if c == nil {
 		return fmt.Errorf("config init: nil config receiver")
 	}
@@ -93,9 +141,11 @@ func (c *Config) Create(project, baseCfgName, baseDirname string, cfgType Config
 	c.cfgType = cfgType
 	c.project = "." + safeProject
 	c.cfgBaseName = safeCfgBaseName
+	c.searchDirs = nil
+	c.legacyPath = ""
 
 	if baseDirname == "" {
-		home, err := os.UserHomeDir()
+		home, err := c.homeDir()
 		if err != nil {
 			return fmt.Errorf("config init: getting home dir: %w", err)
 		}
@@ -105,9 +155,16 @@ func (c *Config) Create(project, baseCfgName, baseDirname string, cfgType Config
 			return fmt.Errorf("config init: resolving home dir: %w", err)
 		}
 
-		c.dirname = filepath.Join(home, c.project)
-		if !isWithinBaseDir(home, c.dirname) {
-			return fmt.Errorf("config init: resolved config directory %q escapes home directory %q", c.dirname, home)
+		switch c.location {
+		case LocationXDG:
+			if err := c.resolveXDG(home, safeProject); err != nil {
+				return fmt.Errorf("config init: %w", err)
+			}
+		default:
+			c.dirname = filepath.Join(home, c.project)
+			if !isWithinBaseDir(home, c.dirname) {
+				return fmt.Errorf("config init: resolved config directory %q escapes home directory %q", c.dirname, home)
+			}
 		}
 	} else {
 		// Reject relative paths that contain traversal components before resolution
@@ -119,49 +176,274 @@ func (c *Config) Create(project, baseCfgName, baseDirname string, cfgType Config
 		c.dirname = cleaned
 	}
 
-	if err := os.MkdirAll(c.dirname, 0o700); err != nil {
+	c.cfgPath = ""
+	if err := c.applyConfigEnv(); err != nil {
+		return fmt.Errorf("config init: %w", err)
+	}
+
+	return nil
+}
+
+// EnsureDir creates the directory of the resolved config file with mode 0700
+// if it does not exist.
+func (c *Config) EnsureDir() error {
+	if c.dirname == "" {
+		return fmt.Errorf("config init: Resolve must be called first")
+	}
+
+	if err := c.fs.MkdirAll(c.dirname, 0o700); err != nil {
 		return fmt.Errorf("config init: creating %q directory: %w", c.dirname, err)
 	}
 
-	c.v.SetConfigFile(c.configFilePath())
-	c.v.SetConfigType(c.cfgType.String())
 	return nil
 }
 
-// ReadFile reads a config file and unmarshals it into s.
-// Unknown fields are rejected.
+// Path returns the config file path resolved by Create, Resolve or Discover.
+func (c *Config) Path() string {
+	if c.dirname == "" && c.cfgPath == "" {
+		return ""
+	}
+	return c.configFilePath()
+}
+
+// ReadFile reads the config file, or every layer set by SetFileSources, plus
+// any drop-in fragments, and unmarshals the merged settings into s. Unknown
+// fields are rejected.
+//
+// Each call replaces the files read by an earlier call, so keys removed from
+// a file are no longer set after the next ReadFile.
+//
+// When values fail to decode, s still receives the values that did, and only
+// those count as present, so Check reports what the error leaves missing.
 func (c *Config) ReadFile(s any) error {
 	rv, err := structValueFromPointer(s)
 	if err != nil {
 		return fmt.Errorf("readfile: %w", err)
 	}
 
-	if err := c.v.ReadInConfig(); err != nil {
-		var notFound viper.ConfigFileNotFoundError
-		var parseErr viper.ConfigParseError
+	sources := c.fileSources()
+	dropIns, err := c.dropInSources()
+	if err != nil {
+		return fmt.Errorf("readfile: listing drop-in directory %q: %w", c.dropInDir, err)
+	}
 
-		cfgName := filepath.Base(c.configFilePath())
-		switch {
-		case errors.As(err, &notFound):
-			return fmt.Errorf("readfile: missing %q", cfgName)
-		case errors.As(err, &parseErr):
-			return fmt.Errorf("readfile: invalid config file %q: %w", cfgName, err)
-		default:
-			return fmt.Errorf("readfile: unable to read %q: %w", cfgName, err)
+	layers := make([]loadedSource, 0, len(sources)+len(dropIns))
+	for i, src := range append(sources[:len(sources):len(sources)], dropIns...) {
+		layer, err := c.readFileSource(src)
+		if err != nil {
+			var parseErr *ParseError
+
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				if src.Optional {
+					continue
+				}
+				return fmt.Errorf("readfile: %w", &FileNotFoundError{Paths: []string{src.Path}})
+			case errors.As(err, &parseErr):
+				return fmt.Errorf("readfile: %w", err)
+			default:
+				return fmt.Errorf("readfile: unable to read %q: %w", filepath.Base(src.Path), err)
+			}
+		}
+
+		// Each fragment must be valid on its own so that the error names the
+		// file that introduced the problem.
+		if i >= len(sources) {
+			if err := decodeExact(rv.Type(), layer.settings); err != nil {
+				return prefixErrors("readfile", c.decodeErrors(rv.Type(), err, func(key string) keyOrigin {
+					return locateKey([]loadedSource{layer}, key)
+				}))
+			}
+		}
+		layers = append(layers, layer)
+	}
+
+	if len(layers) == 0 {
+		paths := make([]string, 0, len(sources))
+		for _, src := range sources {
+			paths = append(paths, src.Path)
+		}
+		return fmt.Errorf("readfile: %w", &FileNotFoundError{Paths: paths})
+	}
+
+	loaded := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		loaded = append(loaded, layer.Path)
+	}
+
+	if err := c.mergeLayers(rv, s, layers, true); err != nil {
+		return prefixErrors("readfile", err)
+	}
+
+	c.mu.Lock()
+	c.loaded = loaded
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ReadReader reads one config document of type t from r, for example stdin
+// for "--config -", and unmarshals it into s. Decoding is as strict as in
+// ReadFile, parse and decode failures are reported the same way, and the
+// keys it sets count as present. The document is merged over the files read
+// so far and stays above them when ReadFile reads the files again; a document
+// that fails to decode is not kept.
+func (c *Config) ReadReader(r io.Reader, t ConfigType, s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("readreader: %w", err)
+	}
+
+	if !t.IsValid() {
+		return fmt.Errorf("readreader: unsupported config type %d", t)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("readreader: unable to read config: %w", err)
+	}
+
+	layer, err := loadDocument(FileSource{Type: t}, t.String()+" input", data)
+	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return fmt.Errorf("readreader: %w", err)
+		}
+		return fmt.Errorf("readreader: unable to read config: %w", err)
+	}
+
+	// The document is kept for later reads, so it must be valid on its own
+	// before it is merged; a rejected document leaves the Config unchanged.
+	if err := decodeExact(rv.Type(), layer.settings); err != nil {
+		return prefixErrors("readreader", c.decodeErrors(rv.Type(), err, func(key string) keyOrigin {
+			return locateKey([]loadedSource{layer}, key)
+		}))
+	}
+
+	if err := c.mergeLayers(rv, s, []loadedSource{layer}, false); err != nil {
+		return prefixErrors("readreader", err)
+	}
+
+	return nil
+}
+
+// mergeLayers merges decoded layers into c.v, strictly unmarshals the result
+// into s and records presence. Decode errors are attributed to the last layer
+// that sets the offending key.
+//
+// With files set, layers replace the files of an earlier ReadFile, so keys
+// removed from a file disappear on the next read; documents kept by
+// ReadReader and ReadKeyDir stay merged over them. Otherwise layers are
+// merged over everything read so far and kept.
+func (c *Config) mergeLayers(rv reflect.Value, s any, layers []loadedSource, files bool) error {
+	merge := layers
+	record := c.recordPresence
+	if files {
+		c.mu.Lock()
+		c.layers = layers
+		c.filePresent = make(map[string]struct{})
+		merge = append(append([]loadedSource(nil), layers...), c.extraLayers...)
+		c.mu.Unlock()
+
+		if err := c.resetConfigLayer(); err != nil {
+			return err
+		}
+		record = c.recordFilePresence
+	} else {
+		c.keepLayers(layers...)
+	}
+
+	for _, layer := range merge {
+		if err := c.v.MergeConfigMap(layer.settings); err != nil {
+			return fmt.Errorf("merging %q: %w", layer.name, err)
 		}
 	}
 
 	if err := c.v.UnmarshalExact(s, decoderOptions()...); err != nil {
-		return fmt.Errorf("readfile: unable to decode %q: %w", filepath.Base(c.configFilePath()), err)
+		// The merged settings hold the kept documents too, so they are
+		// searched along with the files.
+		all := c.configLayers()
+		decodeErr := c.decodeErrors(rv.Type(), err, func(key string) keyOrigin {
+			return locateKey(all, key)
+		})
+
+		// s still holds every value that did decode. Those count as present,
+		// so that Check on the partly filled struct reports only the fields
+		// that are really missing; the values that failed do not.
+		failed := failedKeys(decodeErr)
+		if err := c.recordLayerPresence(rv.Type(), layers, func(path []string) {
+			if key, err := c.settingKey(rv.Type(), path); err == nil {
+				if _, ok := failed[key]; ok {
+					return
+				}
+			}
+			record(path)
+		}); err != nil {
+			return errors.Join(decodeErr, err)
+		}
+		return decodeErr
 	}
 
-	if err := c.recordPresenceFromSettings(rv.Type(), c.rootPathForType(rv.Type()), c.v.AllSettings()); err != nil {
-		return fmt.Errorf("readfile: presence tracking failed: %w", err)
+	return c.recordLayerPresence(rv.Type(), layers, record)
+}
+
+// recordLayerPresence records the fields set by layers. Presence is recorded
+// per layer, so a key set by any layer counts as explicitly set even when a
+// later layer leaves it out.
+func (c *Config) recordLayerPresence(rt reflect.Type, layers []loadedSource, record func(path []string)) error {
+	for _, layer := range layers {
+		if err := c.walkSettingPaths(rt, c.rootPathForType(rt), layer.settings, layer.Type.tagName(), record); err != nil {
+			return fmt.Errorf("presence tracking failed: %w", err)
+		}
 	}
+	return nil
+}
 
+// failedKeys returns the setting keys of the DecodeError values joined in
+// err, cut at the first list index so that they name a field.
+func failedKeys(err error) map[string]struct{} {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+
+	keys := make(map[string]struct{})
+	for _, e := range errs {
+		var decodeErr *DecodeError
+		if errors.As(e, &decodeErr) {
+			key, _, _ := strings.Cut(decodeErr.Key, "[")
+			keys[key] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// resetConfigLayer empties the config layer of c.v. Defaults, environment
+// bindings, flags and overrides are separate layers and stay in place.
+func (c *Config) resetConfigLayer() error {
+	c.v.SetConfigType(ConfigTypeJSON.String())
+	if err := c.v.ReadConfig(strings.NewReader("{}")); err != nil {
+		return fmt.Errorf("resetting config layer: %w", err)
+	}
 	return nil
 }
 
+// keepLayers records documents that are merged over the config files and
+// must survive the next ReadFile.
+func (c *Config) keepLayers(layers ...loadedSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extraLayers = append(c.extraLayers, layers...)
+}
+
+// configLayers returns the file layers followed by the kept documents,
+// lowest precedence first.
+func (c *Config) configLayers() []loadedSource {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append(append([]loadedSource(nil), c.layers...), c.extraLayers...)
+}
+
 // ReadEnv binds environment variables using each field's mapstructure tag
 // and then unmarshals into s.
 func (c *Config) ReadEnv(s any) error {
@@ -175,13 +457,56 @@ func (c *Config) ReadEnv(s any) error {
 	}
 
 	if err := c.v.Unmarshal(s, decoderOptions()...); err != nil {
-		return fmt.Errorf("read environment: error unmarshaling: %w", err)
+		return prefixErrors("read environment", c.decodeErrors(rv.Type(), err, func(key string) keyOrigin {
+			name := key[strings.LastIndexByte(key, '.')+1:]
+			name, _, _ = strings.Cut(name, "[")
+			return keyOrigin{source: "env", key: strings.ToUpper(envFieldName(c.envPrefix, name))}
+		}))
 	}
 
 	return nil
 }
 
 func (c *Config) bindEnvStruct(rv reflect.Value, path []string) error {
+	rt := rv.Type()
+	err := c.walkEnvFields(rv, path, func(fm fieldMeta, fieldPath []string, _ reflect.Value) error {
+		if err := c.v.BindEnv(fm.mapTag); err != nil {
+			return fmt.Errorf("bind env for %q (%s): %w", fm.name, fm.mapTag, err)
+		}
+
+		if c.fileEnvEnabled(fm) {
+			value, ok, err := c.readFileEnv(fm)
+			if err != nil {
+				return err
+			}
+			if ok {
+				// Secrets go to the override layer like dotenv values, under
+				// the full key of nested fields.
+				key, err := c.settingKey(rt, fieldPath)
+				if err != nil {
+					return err
+				}
+				if c.setEnvValue(key, value) {
+					c.recordPresence(fieldPath)
+				}
+			}
+		}
+
+		if c.v.IsSet(fm.mapTag) {
+			c.recordPresence(fieldPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read environment: %w", err)
+	}
+	return nil
+}
+
+// walkEnvFields calls fn for every field of rv that binds to a single key,
+// recursing into config:"struct" fields. Env, flag and other flat sources
+// share this walk so they agree on which fields they populate.
+func (c *Config) walkEnvFields(rv reflect.Value, path []string, fn func(fm fieldMeta, fieldPath []string, field reflect.Value) error) error {
 	metas, err := c.getOrBuildFieldMeta(rv.Type())
 	if err != nil {
 		return err
@@ -194,8 +519,8 @@ func (c *Config) bindEnvStruct(rv reflect.Value, path []string) error {
 		if fm.isStruct {
 			nested, ok := ensureStructValue(field)
 			if ok {
-				if err := c.bindEnvStruct(nested, fieldPath); err != nil {
-					return fmt.Errorf("read environment: nested struct %q: %w", fm.name, err)
+				if err := c.walkEnvFields(nested, fieldPath, fn); err != nil {
+					return fmt.Errorf("nested struct %q: %w", fm.name, err)
 				}
 			}
 		}
@@ -216,12 +541,8 @@ func (c *Config) bindEnvStruct(rv reflect.Value, path []string) error {
 			}
 		}
 
-		if err := c.v.BindEnv(fm.mapTag); err != nil {
-			return fmt.Errorf("read environment: bind env for %q (%s): %w", fm.name, fm.mapTag, err)
-		}
-
-		if c.v.IsSet(fm.mapTag) {
-			c.recordPresence(fieldPath)
+		if err := fn(fm, fieldPath, field); err != nil {
+			return err
 		}
 	}
 
@@ -229,51 +550,100 @@ func (c *Config) bindEnvStruct(rv reflect.Value, path []string) error {
 }
 
 // Check validates required fields and applies defaults.
+//
+// The whole struct tree is checked: every missing required field, invalid
+// default and unparsable tag is reported, each naming the field by its full
+// path such as Server.Port. The failures are combined with errors.Join, so
+// errors.Is and errors.As see each of them.
 func (c *Config) Check(s any) error {
 	rv, err := structValueFromPointer(s)
 	if err != nil {
 		return fmt.Errorf("config check: %w", err)
 	}
 
-	return c.checkStruct(rv, c.rootPathForType(rv.Type()))
+	var errs []error
+	c.checkStruct(rv, c.rootPathForType(rv.Type()), nil, &errs)
+	return errors.Join(errs...)
 }
 
-func (c *Config) checkStruct(rv reflect.Value, path []string) error {
+// checkStruct applies defaults below rv and appends every failure to errs.
+// keys is the file key path of rv.
+func (c *Config) checkStruct(rv reflect.Value, path, keys []string, errs *[]error) {
 	metas, err := c.getOrBuildFieldMeta(rv.Type())
 	if err != nil {
-		return err
+		if len(path) > 1 {
+			err = fmt.Errorf("nested struct %q: %w", fieldDisplayPath(path), err)
+		}
+		*errs = append(*errs, fmt.Errorf("config check: %w", err))
+		return
 	}
 
 	for _, fm := range metas {
 		fv := rv.FieldByIndex(fm.index)
 		fieldPath := appendPath(path, fm.name)
+		fieldKeys := appendPath(keys, fileKeyForField(fm, c.cfgType.tagName()))
 
 		if fm.isStruct {
-			nested, ok := ensureStructValue(fv)
-			if ok {
-				if err := c.checkStruct(nested, fieldPath); err != nil {
-					return fmt.Errorf("config check: nested struct %q: %w", fm.name, err)
-				}
+			if nested, ok := ensureStructValue(fv); ok {
+				c.checkStruct(nested, fieldPath, fieldKeys, errs)
 			}
 
-			if fm.required && !c.hasAnyPresence(fieldPath) && isZeroValue(fv) && fm.defaultVal == nil {
-				return fmt.Errorf("config check: missing required %q for field %q", envFieldName(c.envPrefix, fm.mapTag), fm.name)
+			if fm.required && !c.hasAnyPresence(fieldPath) && !c.hasAnyDefault(fieldPath) && isZeroValue(fv) && fm.defaultVal == nil {
+				*errs = append(*errs, fmt.Errorf("config check: %w", c.missingField(fm, fieldPath, fieldKeys)))
 			}
 			continue
 		}
 
-		if !c.hasPresence(fieldPath) && isZeroValue(fv) && fm.defaultVal != nil {
+		if !c.hasPresence(fieldPath) && !c.hasDefault(fieldPath) && isZeroValue(fv) && fm.defaultVal != nil {
 			if err := setFieldDefault(rv, fm, *fm.defaultVal); err != nil {
-				return fmt.Errorf("config check: default for field %q: %w", fm.name, err)
+				// setValueFromString names the field itself; keep the cause.
+				if cause := errors.Unwrap(err); cause != nil {
+					err = cause
+				}
+				*errs = append(*errs, fmt.Errorf("config check: %w", &DecodeError{
+					Source:   "default",
+					Key:      fieldDisplayPath(fieldPath),
+					Expected: fv.Type().String(),
+					Err:      err,
+				}))
+				continue
 			}
 		}
 
-		if fm.required && !c.hasPresence(fieldPath) && fm.defaultVal == nil && isZeroValue(fv) {
-			return fmt.Errorf("config check: missing required %q for field %q", envFieldName(c.envPrefix, fm.mapTag), fm.name)
+		if fm.required && !c.hasPresence(fieldPath) && !c.hasDefault(fieldPath) && fm.defaultVal == nil && isZeroValue(fv) {
+			*errs = append(*errs, fmt.Errorf("config check: %w", c.missingField(fm, fieldPath, fieldKeys)))
 		}
 	}
+}
 
-	return nil
+func (c *Config) missingField(fm fieldMeta, fieldPath, keys []string) *MissingFieldError {
+	err := &MissingFieldError{Field: fieldDisplayPath(fieldPath), Key: strings.Join(keys, ".")}
+	if fm.mapTag != "" && fm.mapTag != "-" {
+		err.EnvVar = envFieldName(c.envPrefix, fm.mapTag)
+	}
+
+	// Point at the closest enclosing section that a file does set, where the
+	// key would have to be added.
+	layers := c.configLayers()
+	for i := len(keys) - 1; i > 0; i-- {
+		section := strings.ToLower(strings.Join(keys[:i], "."))
+		for j := len(layers) - 1; j >= 0; j-- {
+			layer := layers[j]
+			if !settingsHaveKey(layer.settings, section) {
+				continue
+			}
+			pos := layer.positions[section]
+			err.File, err.Line, err.Column = layer.name, pos.line, pos.col
+			return err
+		}
+	}
+	return err
+}
+
+// fieldDisplayPath joins a field path without its leading type name, as in
+// Server.Port.
+func fieldDisplayPath(path []string) string {
+	return strings.Join(path[1:], ".")
 }
 
 func (c *Config) getOrBuildFieldMeta(rt reflect.Type) ([]fieldMeta, error) {
@@ -304,17 +674,16 @@ func (c *Config) getOrBuildFieldMeta(rt reflect.Type) ([]fieldMeta, error) {
 		}
 
 		fm := fieldMeta{
-			name:    sf.Name,
-			index:   []int{i},
-			mapTag:  cleanTagValue(sf.Tag.Get(tagMapStructure)),
-			yamlTag: cleanTagValue(sf.Tag.Get(tagYAML)),
-			jsonTag: cleanTagValue(sf.Tag.Get(tagJSON)),
+			name:   sf.Name,
+			index:  []int{i},
+			mapTag: cleanTagValue(sf.Tag.Get(tagMapStructure)),
+			tags:   sf.Tag,
 		}
 
 		parts := strings.Split(rawTag, ",")
 	parseLoop:
-		for i, part := range parts {
-			part = strings.TrimSpace(part)
+		for i := 0; i < len(parts); i++ {
+			part := strings.TrimSpace(parts[i])
 			switch {
 			case part == "":
 				continue
@@ -322,25 +691,44 @@ func (c *Config) getOrBuildFieldMeta(rt reflect.Type) ([]fieldMeta, error) {
 				fm.isStruct = true
 			case strings.EqualFold(part, "required"):
 				fm.required = true
+			case strings.EqualFold(part, "file"):
+				fm.fileEnv = true
+			case strings.HasPrefix(strings.ToLower(part), "desc="):
+				// desc= ends at the next comma unless the text is quoted with
+				// single quotes, as in desc='host, or IP address'.
+				desc := strings.TrimSpace(part[len("desc="):])
+				if strings.HasPrefix(desc, "'") {
+					for (len(desc) < 2 || !strings.HasSuffix(desc, "'")) && i+1 < len(parts) {
+						i++
+						desc += "," + parts[i]
+						desc = strings.TrimRight(desc, " ")
+					}
+					if len(desc) < 2 || !strings.HasSuffix(desc, "'") {
+						return nil, &TagError{Type: rt.String(), Field: sf.Name, Token: part, Reason: "unterminated quote in"}
+					}
+					desc = desc[1 : len(desc)-1]
+				}
+				fm.desc = desc
 			case strings.HasPrefix(strings.ToLower(part), "default="):
 				// Policy tokens must precede default=. default= is terminal:
 				// everything after it (including commas) is the default value.
 				for _, tail := range parts[i+1:] {
 					tail = strings.TrimSpace(tail)
-					if strings.EqualFold(tail, "required") || strings.EqualFold(tail, "struct") {
-						return nil, fmt.Errorf("config tag on field %q: policy option %q must appear before default=", sf.Name, tail)
+					if strings.EqualFold(tail, "required") || strings.EqualFold(tail, "struct") || strings.EqualFold(tail, "file") ||
+						strings.HasPrefix(strings.ToLower(tail), "desc=") {
+						return nil, &TagError{Type: rt.String(), Field: sf.Name, Token: tail, Reason: "default= must be last, found policy option"}
 					}
 				}
 				full := strings.Join(parts[i:], ",")
 				kv := strings.SplitN(full, "=", 2)
 				if len(kv) != 2 {
-					return nil, fmt.Errorf("config tag on field %q: invalid default expression %q", sf.Name, part)
+					return nil, &TagError{Type: rt.String(), Field: sf.Name, Token: part, Reason: "invalid default expression"}
 				}
 				value := kv[1]
 				fm.defaultVal = &value
 				break parseLoop
 			default:
-				return nil, fmt.Errorf("config tag on field %q: unsupported token %q", sf.Name, part)
+				return nil, &TagError{Type: rt.String(), Field: sf.Name, Token: part, Reason: "unsupported token"}
 			}
 		}
 
@@ -351,7 +739,9 @@ func (c *Config) getOrBuildFieldMeta(rt reflect.Type) ([]fieldMeta, error) {
 	return metas, nil
 }
 
-func (c *Config) recordPresenceFromSettings(rt reflect.Type, path []string, settings map[string]any) error {
+// walkSettingPaths calls fn with the path of every field of rt that settings
+// sets, recursing into config:"struct" fields.
+func (c *Config) walkSettingPaths(rt reflect.Type, path []string, settings map[string]any, tagName string, fn func(path []string)) error {
 	metas, err := c.getOrBuildFieldMeta(rt)
 	if err != nil {
 		return err
@@ -359,12 +749,12 @@ func (c *Config) recordPresenceFromSettings(rt reflect.Type, path []string, sett
 
 	for _, fm := range metas {
 		fieldPath := appendPath(path, fm.name)
-		settingValue, ok := findSettingValue(settings, fm)
+		settingValue, ok := findSettingValue(settings, fm, tagName)
 		if !ok {
 			continue
 		}
 
-		c.recordPresence(fieldPath)
+		fn(fieldPath)
 
 		if !fm.isStruct {
 			continue
@@ -380,7 +770,7 @@ func (c *Config) recordPresenceFromSettings(rt reflect.Type, path []string, sett
 			continue
 		}
 
-		if err := c.recordPresenceFromSettings(nestedType, fieldPath, nestedSettings); err != nil {
+		if err := c.walkSettingPaths(nestedType, fieldPath, nestedSettings, tagName, fn); err != nil {
 			return err
 		}
 	}
@@ -504,7 +894,10 @@ func isWithinBaseDir(baseDir, target string) bool {
 }
 
 func (c *Config) configFilePath() string {
-	return filepath.Join(c.dirname, c.cfgBaseName+"."+c.cfgType.String())
+	if c.cfgPath != "" {
+		return c.cfgPath
+	}
+	return filepath.Join(c.dirname, c.cfgBaseName+"."+c.cfgType.extension())
 }
 
 func appendPath(path []string, elem string) []string {
@@ -528,10 +921,22 @@ func (c *Config) recordPresence(path []string) {
 	c.present[pathKey(path)] = struct{}{}
 }
 
+// recordFilePresence records presence from the files of the latest
+// ReadFile, which is discarded when the files are read again.
+func (c *Config) recordFilePresence(path []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filePresent[pathKey(path)] = struct{}{}
+}
+
 func (c *Config) hasPresence(path []string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	_, ok := c.present[pathKey(path)]
+	key := pathKey(path)
+	_, ok := c.present[key]
+	if !ok {
+		_, ok = c.filePresent[key]
+	}
 	return ok
 }
 
@@ -540,21 +945,21 @@ func (c *Config) hasAnyPresence(path []string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if _, ok := c.present[prefix]; ok {
-		return true
-	}
-
-	prefix += "."
-	for key := range c.present {
-		if strings.HasPrefix(key, prefix) {
+	for _, present := range []map[string]struct{}{c.present, c.filePresent} {
+		if _, ok := present[prefix]; ok {
 			return true
 		}
+		for key := range present {
+			if strings.HasPrefix(key, prefix+".") {
+				return true
+			}
+		}
 	}
 	return false
 }
 
-func findSettingValue(settings map[string]any, fm fieldMeta) (any, bool) {
-	for _, key := range settingKeysForField(fm) {
+func findSettingValue(settings map[string]any, fm fieldMeta, tagName string) (any, bool) {
+	for _, key := range settingKeysForField(fm, tagName) {
 		value, ok := settings[key]
 		if ok {
 			return value, true
@@ -563,9 +968,13 @@ func findSettingValue(settings map[string]any, fm fieldMeta) (any, bool) {
 	return nil, false
 }
 
-func settingKeysForField(fm fieldMeta) []string {
-	keys := make([]string, 0, 4)
-	seen := make(map[string]struct{}, 4)
+// settingKeysForField lists the setting keys that may hold fm's value. The
+// active codec's tag comes first, followed by the tags of every registered
+// codec and the mapstructure-style fallbacks.
+func settingKeysForField(fm fieldMeta, tagName string) []string {
+	tagNames := codecTagNames()
+	keys := make([]string, 0, len(tagNames)+4)
+	seen := make(map[string]struct{}, len(tagNames)+4)
 
 	add := func(key string) {
 		key = strings.TrimSpace(strings.ToLower(key))
@@ -579,8 +988,12 @@ func settingKeysForField(fm fieldMeta) []string {
 		keys = append(keys, key)
 	}
 
-	add(fm.yamlTag)
-	add(fm.jsonTag)
+	if tagName != "" {
+		add(cleanTagValue(fm.tags.Get(tagName)))
+	}
+	for _, name := range tagNames {
+		add(cleanTagValue(fm.tags.Get(name)))
+	}
 	add(toSnakeCase(fm.name))
 	add(strings.ToLower(fm.name))
 	add(strings.ToLower(fm.mapTag))
@@ -645,6 +1058,12 @@ func setFieldDefault(parent reflect.Value, fm fieldMeta, defaultStr string) erro
 		return fmt.Errorf("cannot set default on unaddressable field %q", fm.name)
 	}
 
+	return setValueFromString(fv, fm.name, "default", defaultStr)
+}
+
+// setValueFromString converts raw to the type of fv and stores it. label names
+// the kind of value in error messages, such as "default".
+func setValueFromString(fv reflect.Value, name, label, raw string) error {
 	for fv.Kind() == reflect.Ptr {
 		if fv.IsNil() {
 			fv.Set(reflect.New(fv.Type().Elem()))
@@ -654,49 +1073,49 @@ func setFieldDefault(parent reflect.Value, fm fieldMeta, defaultStr string) erro
 
 	switch fv.Kind() {
 	case reflect.Bool:
-		b, err := strconv.ParseBool(defaultStr)
+		b, err := strconv.ParseBool(raw)
 		if err != nil {
-			return fmt.Errorf("invalid bool default for field %q: %w", fm.name, err)
+			return fmt.Errorf("invalid bool %s for field %q: %w", label, name, err)
 		}
 		fv.SetBool(b)
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		if fv.Type() == timeDurationType {
-			d, err := time.ParseDuration(defaultStr)
+			d, err := time.ParseDuration(raw)
 			if err != nil {
-				return fmt.Errorf("invalid duration default for field %q: %w", fm.name, err)
+				return fmt.Errorf("invalid duration %s for field %q: %w", label, name, err)
 			}
 			fv.SetInt(int64(d))
 			return nil
 		}
-		i, err := strconv.ParseInt(defaultStr, 10, 64)
+		i, err := strconv.ParseInt(raw, 10, 64)
 		if err != nil {
-			return fmt.Errorf("invalid int default for field %q: %w", fm.name, err)
+			return fmt.Errorf("invalid int %s for field %q: %w", label, name, err)
 		}
 		fv.SetInt(i)
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		u, err := strconv.ParseUint(defaultStr, 10, 64)
+		u, err := strconv.ParseUint(raw, 10, 64)
 		if err != nil {
-			return fmt.Errorf("invalid uint default for field %q: %w", fm.name, err)
+			return fmt.Errorf("invalid uint %s for field %q: %w", label, name, err)
 		}
 		fv.SetUint(u)
 
 	case reflect.Float32, reflect.Float64:
-		fl, err := strconv.ParseFloat(defaultStr, 64)
+		fl, err := strconv.ParseFloat(raw, 64)
 		if err != nil {
-			return fmt.Errorf("invalid float default for field %q: %w", fm.name, err)
+			return fmt.Errorf("invalid float %s for field %q: %w", label, name, err)
 		}
 		fv.SetFloat(fl)
 
 	case reflect.String:
-		fv.SetString(defaultStr)
+		fv.SetString(raw)
 
 	case reflect.Slice:
 		if fv.Type().Elem().Kind() != reflect.String {
-			return fmt.Errorf("unsupported slice type %s for default on %q", fv.Type(), fm.name)
+			return fmt.Errorf("unsupported slice type %s for %s on %q", fv.Type(), label, name)
 		}
-		parts := strings.Split(defaultStr, ",")
+		parts := strings.Split(raw, ",")
 		items := make([]string, 0, len(parts))
 		for _, part := range parts {
 			trimmed := strings.TrimSpace(part)
@@ -708,7 +1127,7 @@ func setFieldDefault(parent reflect.Value, fm fieldMeta, defaultStr string) erro
 		fv.Set(reflect.ValueOf(items))
 
 	default:
-		return fmt.Errorf("unsupported kind %s for default on %q", fv.Kind(), fm.name)
+		return fmt.Errorf("unsupported kind %s for %s on %q", fv.Kind(), label, name)
 	}
 	return nil
 }
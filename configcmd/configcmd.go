@@ -0,0 +1,313 @@
+// Package configcmd implements the config subcommands most tools built on
+// autoconfig need: path, show, get, set, unset, edit and validate.
+//
+// The command does not depend on a CLI framework. Hand it the arguments that
+// follow "config" and exit with the returned code:
+//
+//	cmd := configcmd.New(setupConfig, func() any { return new(AppConfig) })
+//	os.Exit(cmd.Run(os.Args[2:]))
+//
+// With cobra, use a command with DisableFlagParsing and call Run from RunE.
+package configcmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/handletec/autoconfig"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// Exit codes returned by Run.
+const (
+	// ExitOK reports success.
+	ExitOK = 0
+	// ExitError reports an I/O or other unexpected failure.
+	ExitError = 1
+	// ExitUsage reports an unknown subcommand or wrong arguments.
+	ExitUsage = 2
+	// ExitInvalid reports a config that fails strict decoding or Check, or a
+	// value rejected for its field type.
+	ExitInvalid = 3
+	// ExitNotFound reports a key that names no field or is not set.
+	ExitNotFound = 4
+)
+
+// Command runs config subcommands against a struct type.
+type Command struct {
+	// Name prefixes usage and error messages. It defaults to "config".
+	Name string
+	// Stdout and Stderr default to os.Stdout and os.Stderr. Stdin is passed
+	// to the editor and defaults to os.Stdin.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	setup     func() (*autoconfig.Config, error)
+	newStruct func() any
+}
+
+// New returns a Command. setup returns a Config on which Create, Resolve or
+// Discover has been called; it is invoked once per load so that every
+// validation starts from a clean state. newStruct returns a pointer to a new
+// value of the config struct.
+func New(setup func() (*autoconfig.Config, error), newStruct func() any) *Command {
+	return &Command{
+		Name:      "config",
+		Stdin:     os.Stdin,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+		setup:     setup,
+		newStruct: newStruct,
+	}
+}
+
+// Run executes the subcommand named by args[0] and returns an exit code.
+func (cmd *Command) Run(args []string) int {
+	if len(args) == 0 {
+		cmd.usage()
+		return ExitUsage
+	}
+
+	name, args := args[0], args[1:]
+	switch name {
+	case "path":
+		return cmd.withArgs(name, args, 0, cmd.runPath)
+	case "show":
+		return cmd.withArgs(name, args, 0, cmd.runShow)
+	case "get":
+		return cmd.withArgs(name, args, 1, cmd.runGet)
+	case "set":
+		return cmd.withArgs(name, args, 2, cmd.runSet)
+	case "unset":
+		return cmd.withArgs(name, args, 1, cmd.runUnset)
+	case "edit":
+		return cmd.withArgs(name, args, 0, cmd.runEdit)
+	case "validate":
+		return cmd.withArgs(name, args, 0, cmd.runValidate)
+	case "help", "-h", "--help":
+		cmd.usage()
+		return ExitOK
+	default:
+		cmd.errorf("unknown subcommand %q", name)
+		cmd.usage()
+		return ExitUsage
+	}
+}
+
+func (cmd *Command) withArgs(name string, args []string, want int, fn func(args []string) int) int {
+	if len(args) != want {
+		cmd.errorf("%s: expected %d argument(s), got %d", name, want, len(args))
+		cmd.usage()
+		return ExitUsage
+	}
+	return fn(args)
+}
+
+func (cmd *Command) usage() {
+	fmt.Fprintf(cmd.Stderr, `usage: %[1]s <subcommand> [arguments]
+
+subcommands:
+  %[1]s path               print the config file path
+  %[1]s show               print the effective configuration
+  %[1]s get <key>          print one value, such as server.port
+  %[1]s set <key> <value>  set a key in the config file
+  %[1]s unset <key>        remove a key from the config file
+  %[1]s edit               open the config file in $VISUAL or $EDITOR, then validate it
+  %[1]s validate           check the config file and environment
+
+exit codes: 0 ok, 1 error, 2 usage, 3 invalid config, 4 key not found
+`, cmd.Name)
+}
+
+func (cmd *Command) errorf(format string, args ...any) {
+	fmt.Fprintf(cmd.Stderr, "%s: %s\n", cmd.Name, fmt.Sprintf(format, args...))
+}
+
+func (cmd *Command) runPath([]string) int {
+	cfg, err := cmd.setup()
+	if err != nil {
+		cmd.errorf("%v", err)
+		return ExitError
+	}
+	fmt.Fprintln(cmd.Stdout, cfg.Path())
+	return ExitOK
+}
+
+func (cmd *Command) runShow([]string) int {
+	cfg, s, code := cmd.load(true)
+	if code != ExitOK {
+		return code
+	}
+
+	data, err := cfg.Marshal(s)
+	if err != nil {
+		cmd.errorf("show: %v", err)
+		return ExitError
+	}
+	_, _ = cmd.Stdout.Write(data)
+	return ExitOK
+}
+
+func (cmd *Command) runGet(args []string) int {
+	cfg, s, code := cmd.load(false)
+	if code != ExitOK {
+		return code
+	}
+
+	value, err := cfg.Lookup(s, args[0])
+	if err != nil {
+		return cmd.fail("get", err)
+	}
+
+	out, err := formatValue(value)
+	if err != nil {
+		cmd.errorf("get: %v", err)
+		return ExitError
+	}
+	fmt.Fprintln(cmd.Stdout, out)
+	return ExitOK
+}
+
+func (cmd *Command) runSet(args []string) int {
+	cfg, err := cmd.setup()
+	if err != nil {
+		cmd.errorf("%v", err)
+		return ExitError
+	}
+	if err := cfg.SetKey(cmd.newStruct(), args[0], args[1]); err != nil {
+		return cmd.fail("set", err)
+	}
+	return ExitOK
+}
+
+func (cmd *Command) runUnset(args []string) int {
+	cfg, err := cmd.setup()
+	if err != nil {
+		cmd.errorf("%v", err)
+		return ExitError
+	}
+	if err := cfg.UnsetKey(cmd.newStruct(), args[0]); err != nil {
+		return cmd.fail("unset", err)
+	}
+	return ExitOK
+}
+
+func (cmd *Command) runEdit([]string) int {
+	cfg, err := cmd.setup()
+	if err != nil {
+		cmd.errorf("%v", err)
+		return ExitError
+	}
+
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	if err := cfg.InitFile(cmd.newStruct()); err != nil && !errors.Is(err, fs.ErrExist) {
+		cmd.errorf("edit: %v", err)
+		return ExitError
+	}
+
+	editCmd := exec.Command(editor[0], append(editor[1:], cfg.Path())...)
+	editCmd.Stdin, editCmd.Stdout, editCmd.Stderr = cmd.Stdin, cmd.Stdout, cmd.Stderr
+	if err := editCmd.Run(); err != nil {
+		cmd.errorf("edit: running %s: %v", editor[0], err)
+		return ExitError
+	}
+
+	return cmd.runValidate(nil)
+}
+
+func (cmd *Command) runValidate([]string) int {
+	cfg, _, code := cmd.load(true)
+	if code != ExitOK {
+		return code
+	}
+	fmt.Fprintf(cmd.Stdout, "%s: ok\n", cfg.Path())
+	return ExitOK
+}
+
+// load reads the config file and the environment into a new struct. A
+// missing file is not an error, since env vars may supply everything. With
+// check set, Check must pass too.
+func (cmd *Command) load(check bool) (*autoconfig.Config, any, int) {
+	cfg, err := cmd.setup()
+	if err != nil {
+		cmd.errorf("%v", err)
+		return nil, nil, ExitError
+	}
+
+	s := cmd.newStruct()
+	if err := cfg.ReadFile(s); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		cmd.errorf("%v", err)
+		return nil, nil, ExitInvalid
+	}
+	if err := cfg.ReadEnv(s); err != nil {
+		cmd.errorf("%v", err)
+		return nil, nil, ExitInvalid
+	}
+
+	err = cfg.Check(s)
+	if err != nil && check {
+		cmd.errorf("%v", err)
+		return nil, nil, ExitInvalid
+	}
+	return cfg, s, ExitOK
+}
+
+// fail reports err and maps it to an exit code.
+func (cmd *Command) fail(name string, err error) int {
+	cmd.errorf("%s: %v", name, err)
+	switch {
+	case errors.Is(err, autoconfig.ErrKeyNotFound):
+		return ExitNotFound
+	case errors.Is(err, autoconfig.ErrInvalidValue):
+		return ExitInvalid
+	default:
+		return ExitError
+	}
+}
+
+// formatValue renders a looked-up value for scripts: scalars as is, lists of
+// scalars comma-separated, anything else as YAML.
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case map[string]any:
+	case []any:
+		if items, ok := scalarItems(v); ok {
+			return strings.Join(items, ","), nil
+		}
+	default:
+		return fmt.Sprint(v), nil
+	}
+
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func scalarItems(list []any) ([]string, bool) {
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		switch item.(type) {
+		case map[string]any, []any:
+			return nil, false
+		}
+		items = append(items, fmt.Sprint(item))
+	}
+	return items, true
+}
@@ -0,0 +1,122 @@
+package configcmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/handletec/autoconfig"
+)
+
+type serverConfig struct {
+	Port int `yaml:"port" mapstructure:"PORT" config:"default=8000"`
+}
+
+type appConfig struct {
+	Address string       `yaml:"address" mapstructure:"ADDRESS" config:"required"`
+	Origin  []string     `yaml:"origin" mapstructure:"ORIGIN"`
+	Server  serverConfig `yaml:"server" config:"struct"`
+}
+
+func newTestCommand(t *testing.T, prefix string) (*Command, *bytes.Buffer, *bytes.Buffer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	setup := func() (*autoconfig.Config, error) {
+		cfg := autoconfig.New(prefix)
+		return cfg, cfg.Resolve("app", "config", dir, autoconfig.ConfigTypeYAML)
+	}
+
+	cmd := New(setup, func() any { return new(appConfig) })
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return cmd, stdout, stderr, filepath.Join(dir, "config.yaml")
+}
+
+func TestSubcommandsAndExitCodes(t *testing.T) {
+	cmd, stdout, stderr, path := newTestCommand(t, "CONFIGCMDTEST")
+
+	if code := cmd.Run([]string{"path"}); code != ExitOK || strings.TrimSpace(stdout.String()) != path {
+		t.Fatalf("path: code %d, output %q", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := cmd.Run([]string{"validate"}); code != ExitInvalid {
+		t.Fatalf("validate without address: expected %d, got %d (%s)", ExitInvalid, code, stderr.String())
+	}
+
+	if code := cmd.Run([]string{"set", "address", "0.0.0.0"}); code != ExitOK {
+		t.Fatalf("set: code %d: %s", code, stderr.String())
+	}
+	if code := cmd.Run([]string{"set", "origin", "a,b"}); code != ExitOK {
+		t.Fatalf("set: code %d: %s", code, stderr.String())
+	}
+	if code := cmd.Run([]string{"set", "server.port", "many"}); code != ExitInvalid {
+		t.Fatalf("set invalid value: expected %d, got %d", ExitInvalid, code)
+	}
+	if code := cmd.Run([]string{"set", "server.prot", "1"}); code != ExitNotFound {
+		t.Fatalf("set unknown key: expected %d, got %d", ExitNotFound, code)
+	}
+
+	stdout.Reset()
+	if code := cmd.Run([]string{"get", "server.port"}); code != ExitOK || strings.TrimSpace(stdout.String()) != "8000" {
+		t.Fatalf("get default: code %d, output %q", code, stdout.String())
+	}
+	stdout.Reset()
+	if code := cmd.Run([]string{"get", "origin"}); code != ExitOK || strings.TrimSpace(stdout.String()) != "a,b" {
+		t.Fatalf("get list: code %d, output %q", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := cmd.Run([]string{"validate"}); code != ExitOK {
+		t.Fatalf("validate: code %d: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := cmd.Run([]string{"show"}); code != ExitOK || !strings.Contains(stdout.String(), "address: 0.0.0.0") {
+		t.Fatalf("show: code %d, output %q", code, stdout.String())
+	}
+
+	if code := cmd.Run([]string{"unset", "origin"}); code != ExitOK {
+		t.Fatalf("unset: code %d: %s", code, stderr.String())
+	}
+	if code := cmd.Run([]string{"unset", "origin"}); code != ExitNotFound {
+		t.Fatalf("unset missing key: expected %d, got %d", ExitNotFound, code)
+	}
+
+	if code := cmd.Run([]string{"get"}); code != ExitUsage {
+		t.Fatalf("get without key: expected %d, got %d", ExitUsage, code)
+	}
+	if code := cmd.Run([]string{"frobnicate"}); code != ExitUsage {
+		t.Fatalf("unknown subcommand: expected %d, got %d", ExitUsage, code)
+	}
+}
+
+func TestEditRunsEditorAndValidates(t *testing.T) {
+	cmd, stdout, stderr, path := newTestCommand(t, "CONFIGCMDTEST2")
+
+	script := filepath.Join(t.TempDir(), "editor.sh")
+	body := "#!/bin/sh\nprintf 'address: edited\\nserver:\\n  port: 9000\\n' > \"$1\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", script)
+
+	if code := cmd.Run([]string{"edit"}); code != ExitOK {
+		t.Fatalf("edit: code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), path+": ok") {
+		t.Fatalf("expected validation output, got %q", stdout.String())
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.sh")
+	if err := os.WriteFile(bad, []byte("#!/bin/sh\nprintf 'adress: typo\\n' > \"$1\"\n"), 0o700); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	t.Setenv("EDITOR", bad)
+	if code := cmd.Run([]string{"edit"}); code != ExitInvalid {
+		t.Fatalf("edit with unknown key: expected %d, got %d", ExitInvalid, code)
+	}
+}
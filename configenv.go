@@ -0,0 +1,47 @@
+package autoconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// configEnvKey is the key of the env var that overrides the config file path.
+const configEnvKey = "CONFIG"
+
+// SetConfigEnv changes the name of the environment variable that overrides
+// the config file path in Create. The default is <PREFIX>_CONFIG; without a
+// prefix there is no default and the override is only enabled by naming a
+// variable here. An empty name disables the override.
+func (c *Config) SetConfigEnv(name string) {
+	c.cfgEnv = name
+}
+
+// applyConfigEnv points the config file at the path named by the config env
+// var, if set. The directory follows the file so that drop-ins and directory
+// creation apply next to it.
+func (c *Config) applyConfigEnv() error {
+	if c.cfgEnv == "" {
+		return nil
+	}
+
+	raw, ok := os.LookupEnv(c.cfgEnv)
+	if !ok || raw == "" {
+		return nil
+	}
+
+	cleaned := filepath.Clean(raw)
+	if !filepath.IsAbs(cleaned) {
+		return fmt.Errorf("%s=%q must be an absolute path", c.cfgEnv, raw)
+	}
+
+	ct, ok := configTypeForExtension(filepath.Ext(cleaned))
+	if !ok {
+		return fmt.Errorf("%s=%q: cannot infer config type from extension", c.cfgEnv, raw)
+	}
+
+	c.cfgPath = cleaned
+	c.cfgType = ct
+	c.dirname = filepath.Dir(cleaned)
+	return nil
+}
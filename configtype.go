@@ -1,24 +1,47 @@
 package autoconfig
 
+// ConfigType selects the codec used for a configuration file. Built-in types
+// are declared below; RegisterCodec allocates further values.
 type ConfigType uint8
 
 const (
 	ConfigTypeNone ConfigType = iota
 	ConfigTypeYAML
 	ConfigTypeJSON
+	ConfigTypeTOML
+	ConfigTypeINI
+	ConfigTypeProperties
 )
 
+// IsValid reports whether ct refers to a registered codec.
 func (ct ConfigType) IsValid() bool {
-	return ct == ConfigTypeYAML || ct == ConfigTypeJSON
+	_, ok := lookupCodec(ct)
+	return ok
 }
 
+// String returns the codec name, such as "yaml", or "none".
 func (ct ConfigType) String() string {
-	switch ct {
-	case ConfigTypeYAML:
-		return "yaml"
-	case ConfigTypeJSON:
-		return "json"
-	default:
+	entry, ok := lookupCodec(ct)
+	if !ok {
 		return "none"
 	}
+	return entry.name
+}
+
+// extension returns the file extension used when building config paths.
+func (ct ConfigType) extension() string {
+	entry, ok := lookupCodec(ct)
+	if !ok {
+		return "none"
+	}
+	return entry.codec.Extensions()[0]
+}
+
+// tagName returns the struct tag consulted for presence tracking.
+func (ct ConfigType) tagName() string {
+	entry, ok := lookupCodec(ct)
+	if !ok {
+		return ""
+	}
+	return entry.codec.TagName()
 }
@@ -0,0 +1,99 @@
+package autoconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ReadDefaults reads a defaults document of type t from r, typically a
+// go:embed bundle, and layers it beneath every other source. It is strictly
+// decoded against s and unmarshaled into it.
+//
+// Values from the document count as defaults, not as presence: they satisfy
+// required fields the document covers and take the place of default= tags,
+// while required fields it leaves out still fail Check until another source
+// sets them.
+func (c *Config) ReadDefaults(r io.Reader, t ConfigType, s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("read defaults: %w", err)
+	}
+
+	if !t.IsValid() {
+		return fmt.Errorf("read defaults: unsupported config type %d", t)
+	}
+
+	settings, err := decodeSettings(r, t)
+	if err != nil {
+		var parseErr viper.ConfigParseError
+		if errors.As(err, &parseErr) {
+			return fmt.Errorf("read defaults: invalid %s document: %w", t, err)
+		}
+		return fmt.Errorf("read defaults: unable to read document: %w", err)
+	}
+
+	if err := decodeExact(rv.Type(), settings); err != nil {
+		return fmt.Errorf("read defaults: unable to decode %s document: %w", t, err)
+	}
+
+	setNestedDefaults(c.v, nil, settings)
+
+	err = c.walkSettingPaths(rv.Type(), c.rootPathForType(rv.Type()), settings, t.tagName(), c.recordDefault)
+	if err != nil {
+		return fmt.Errorf("read defaults: %w", err)
+	}
+
+	if err := c.v.Unmarshal(s, decoderOptions()...); err != nil {
+		return fmt.Errorf("read defaults: error unmarshaling: %w", err)
+	}
+
+	return nil
+}
+
+// setNestedDefaults registers every leaf of settings as a Viper default so
+// that files setting sibling keys do not hide the rest of a nested map.
+func setNestedDefaults(v *viper.Viper, prefix []string, settings map[string]any) {
+	for key, value := range settings {
+		path := append(prefix[:len(prefix):len(prefix)], key)
+		if nested, ok := toStringAnyMap(value); ok && len(nested) > 0 {
+			setNestedDefaults(v, path, nested)
+			continue
+		}
+		v.SetDefault(strings.Join(path, "."), value)
+	}
+}
+
+func (c *Config) recordDefault(path []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defaulted[pathKey(path)] = struct{}{}
+}
+
+func (c *Config) hasDefault(path []string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.defaulted[pathKey(path)]
+	return ok
+}
+
+func (c *Config) hasAnyDefault(path []string) bool {
+	prefix := pathKey(path)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.defaulted[prefix]; ok {
+		return true
+	}
+
+	prefix += "."
+	for key := range c.defaulted {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
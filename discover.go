@@ -0,0 +1,93 @@
+package autoconfig
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// vcsMarkers are the entries that mark the root of a repository. Discover
+// does not search above a directory containing one of them.
+var vcsMarkers = []string{".git", ".hg", ".svn", ".bzr", ".jj"}
+
+// Discover sets up the config like Create, but finds the file by walking up
+// from the working directory, as git does for .git. It looks for
+// .<name>.<ext> in each directory, where name defaults to project and both
+// are sanitised as in Create, and returns the path of the first match.
+//
+// The search stops after the filesystem root, a directory holding a VCS
+// marker such as .git, or the home directory. When nothing is found the
+// error wraps fs.ErrNotExist. The <PREFIX>_CONFIG override applies as in
+// Create and skips the search.
+func (c *Config) Discover(project, name string, cfgType ConfigType) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("config discover: nil config receiver")
+	}
+
+	safeProject, err := sanitizeName(project)
+	if err != nil {
+		return "", fmt.Errorf("config discover: invalid project name: %w", err)
+	}
+
+	if name == "" {
+		name = safeProject
+	}
+	safeName, err := sanitizeName(name)
+	if err != nil {
+		return "", fmt.Errorf("config discover: invalid config file name: %w", err)
+	}
+
+	if !cfgType.IsValid() {
+		cfgType = ConfigTypeYAML
+	}
+
+	c.cfgType = cfgType
+	c.project = "." + safeProject
+	c.cfgBaseName = safeName
+	c.searchDirs = nil
+	c.legacyPath = ""
+	c.cfgPath = ""
+
+	if err := c.applyConfigEnv(); err != nil {
+		return "", fmt.Errorf("config discover: %w", err)
+	}
+	if c.cfgPath != "" {
+		return c.cfgPath, nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("config discover: getting working directory: %w", err)
+	}
+
+	home, err := c.homeDir()
+	if err == nil {
+		home = filepath.Clean(home)
+	}
+
+	fileName := "." + safeName + "." + cfgType.extension()
+	for dir := filepath.Clean(wd); ; dir = filepath.Dir(dir) {
+		candidate := filepath.Join(dir, fileName)
+		if c.fileExists(candidate) {
+			c.dirname = dir
+			c.cfgPath = candidate
+			return candidate, nil
+		}
+
+		if dir == home || dir == filepath.Dir(dir) || c.hasVCSMarker(dir) {
+			break
+		}
+	}
+
+	return "", fmt.Errorf("config discover: no %q found from %q: %w", fileName, wd, fs.ErrNotExist)
+}
+
+func (c *Config) hasVCSMarker(dir string) bool {
+	for _, marker := range vcsMarkers {
+		if _, err := c.fs.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package autoconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var dotEnvKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ReadDotEnv reads a dotenv file and applies its entries to the fields of s
+// the same way ReadEnv applies environment variables: an entry named
+// <PREFIX>_<KEY> sets the field with mapstructure:"KEY", and empty values are
+// treated as absent. The process environment is not modified.
+//
+// Values from the file rank with environment variables: a variable that is
+// set in the real environment wins, and dotenv values override config files
+// regardless of the order in which ReadFile and ReadDotEnv are called.
+//
+// The file may use "export" prefixes, # comments, single-quoted literals,
+// double-quoted values with escapes spanning several lines, and $VAR, ${VAR}
+// or ${VAR:-default} expansion against earlier entries and the environment.
+func (c *Config) ReadDotEnv(path string, s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("read dotenv: %w", err)
+	}
+
+	data, err := afero.ReadFile(c.fs, path)
+	if err != nil {
+		return fmt.Errorf("read dotenv: %w", err)
+	}
+
+	vars, err := parseDotEnv(string(data), os.LookupEnv)
+	if err != nil {
+		return fmt.Errorf("read dotenv: %q: %w", path, err)
+	}
+
+	err = c.walkEnvFields(rv, c.rootPathForType(rv.Type()), func(fm fieldMeta, fieldPath []string, _ reflect.Value) error {
+		name := strings.ToUpper(envFieldName(c.envPrefix, fm.mapTag))
+		value, ok := vars[name]
+		if !ok || value == "" {
+			return nil
+		}
+		if real, ok := os.LookupEnv(name); ok && real != "" {
+			return nil
+		}
+
+		// Values go to the override layer, which is decoded by path, so
+		// nested fields need their full key.
+		key, err := c.settingKey(rv.Type(), fieldPath)
+		if err != nil {
+			return err
+		}
+		if c.setEnvValue(key, value) {
+			c.recordPresence(fieldPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read dotenv: %w", err)
+	}
+
+	if err := c.v.Unmarshal(s, decoderOptions()...); err != nil {
+		return fmt.Errorf("read dotenv: error unmarshaling: %w", err)
+	}
+
+	return nil
+}
+
+// setEnvValue stores a value obtained on behalf of the environment. Viper
+// only reads env vars from the process, so the value is placed in the
+// override layer unless a command-line flag, applied by ApplyFlags or bound
+// by BindPFlags, already set the key.
+func (c *Config) setEnvValue(key, value string) bool {
+	c.mu.RLock()
+	if _, ok := c.flagKeys[strings.ToLower(key)]; ok {
+		c.mu.RUnlock()
+		return false
+	}
+	for _, b := range c.pflags {
+		if !b.flag.Changed {
+			continue
+		}
+		for _, bound := range b.keys {
+			if strings.EqualFold(bound, key) {
+				c.mu.RUnlock()
+				return false
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	c.v.Set(key, value)
+	return true
+}
+
+// parseDotEnv parses dotenv syntax. lookup resolves variables that are not
+// defined earlier in the file.
+func parseDotEnv(data string, lookup func(string) (string, bool)) (map[string]string, error) {
+	vars := make(map[string]string)
+	resolve := func(name string) (string, bool) {
+		if value, ok := vars[name]; ok {
+			return value, true
+		}
+		return lookup(name)
+	}
+
+	lines := strings.Split(strings.ReplaceAll(strings.TrimPrefix(data, "\ufeff"), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "export "); ok {
+			line = strings.TrimSpace(rest)
+		}
+
+		idx := strings.IndexByte(line, '=')
+		if idx <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+
+		key := strings.TrimSpace(line[:idx])
+		if !dotEnvKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNo, key)
+		}
+
+		raw := strings.TrimLeft(line[idx+1:], " \t")
+		var value string
+		switch {
+		case strings.HasPrefix(raw, "'"):
+			end := strings.IndexByte(raw[1:], '\'')
+			for end < 0 && i+1 < len(lines) {
+				i++
+				raw += "\n" + lines[i]
+				end = strings.IndexByte(raw[1:], '\'')
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single-quoted value", lineNo)
+			}
+			if err := checkTrailing(raw[end+2:]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value = raw[1 : end+1]
+
+		case strings.HasPrefix(raw, `"`):
+			end := closingDoubleQuote(raw)
+			for end < 0 && i+1 < len(lines) {
+				i++
+				raw += "\n" + lines[i]
+				end = closingDoubleQuote(raw)
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated double-quoted value", lineNo)
+			}
+			if err := checkTrailing(raw[end+1:]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value = expandDotEnv(raw[1:end], resolve, true)
+
+		default:
+			if idx := strings.Index(raw, " #"); idx >= 0 {
+				raw = raw[:idx]
+			}
+			if idx := strings.Index(raw, "\t#"); idx >= 0 {
+				raw = raw[:idx]
+			}
+			value = expandDotEnv(strings.TrimSpace(raw), resolve, false)
+		}
+
+		vars[key] = value
+	}
+
+	return vars, nil
+}
+
+// closingDoubleQuote returns the index of the unescaped quote that closes the
+// double-quoted value starting at raw[0], or -1.
+func closingDoubleQuote(raw string) int {
+	for i := 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func checkTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected characters %q after quoted value", rest)
+	}
+	return nil
+}
+
+// expandDotEnv substitutes $VAR, ${VAR} and ${VAR:-default}. With escapes set,
+// backslash sequences of double-quoted values are decoded and \$ suppresses
+// expansion.
+func expandDotEnv(value string, resolve func(string) (string, bool), escapes bool) string {
+	var b strings.Builder
+	b.Grow(len(value))
+
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+
+		if escapes && ch == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\', '$':
+				b.WriteByte(value[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+
+		if ch != '$' || i+1 == len(value) {
+			b.WriteByte(ch)
+			continue
+		}
+
+		if value[i+1] == '{' {
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				b.WriteByte(ch)
+				continue
+			}
+			expr := value[i+2 : i+2+end]
+			name, fallback, hasFallback := strings.Cut(expr, ":-")
+			resolved, ok := resolve(name)
+			if (!ok || resolved == "") && hasFallback {
+				resolved = fallback
+			}
+			b.WriteString(resolved)
+			i += end + 2
+			continue
+		}
+
+		end := i + 1
+		for end < len(value) && isDotEnvNameByte(value[end], end == i+1) {
+			end++
+		}
+		if end == i+1 {
+			b.WriteByte(ch)
+			continue
+		}
+		resolved, _ := resolve(value[i+1 : end])
+		b.WriteString(resolved)
+		i = end - 1
+	}
+
+	return b.String()
+}
+
+func isDotEnvNameByte(ch byte, first bool) bool {
+	switch {
+	case ch == '_', ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z':
+		return true
+	case ch >= '0' && ch <= '9':
+		return !first
+	default:
+		return false
+	}
+}
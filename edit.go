@@ -0,0 +1,299 @@
+package autoconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/afero"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// keyStep is one segment of a dotted key resolved against a struct type.
+type keyStep struct {
+	// names are the spellings that name the field in a dotted key or in the
+	// file, such as its yaml tag.
+	names []string
+	// write is the key ReadFile decodes the field from. SetKey writes it for
+	// new keys and renames existing keys spelled otherwise.
+	write string
+	fm    fieldMeta
+	typ   reflect.Type
+}
+
+// SetKey sets a dotted key, such as server.port, in the YAML config file and
+// writes the file back atomically. Comments, key order and formatting of
+// the rest of the document are kept. A missing file or section is created.
+//
+// The key is resolved against the fields of s like the keys of a config
+// file, and value is validated with the conversions used for default=
+// values, so only scalar and []string fields can be set. Keys are written as
+// ReadFile decodes them, like WriteFile, so a key spelled after a codec tag
+// is renamed. s itself is not modified; call ReadFile to load the new value.
+func (c *Config) SetKey(s any, key, value string) error {
+	steps, err := c.resolveKey(s, key)
+	if err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+
+	last := steps[len(steps)-1]
+	if last.fm.isStruct {
+		return fmt.Errorf("edit: key %q is a section, not a value", key)
+	}
+
+	typed := reflect.New(last.typ).Elem()
+	if err := setValueFromString(typed, last.fm.name, "value", value); err != nil {
+		return fmt.Errorf("edit: %w: %w", ErrInvalidValue, err)
+	}
+	encoded, _, err := c.encodeValue(typed, tagYAML)
+	if err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+
+	doc, err := c.loadYAMLDocument()
+	if err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+
+	mapping := doc.Content[0]
+	for _, step := range steps[:len(steps)-1] {
+		idx, child := findMappingKey(mapping, step.names)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			mapping.Content = append(mapping.Content, yamlKeyNode(step.write), child)
+		} else {
+			acceptKeyNode(mapping.Content[idx], step.write)
+			if child.Kind != yaml.MappingNode {
+				*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: child.HeadComment, LineComment: child.LineComment}
+			}
+		}
+		mapping = child
+	}
+
+	node := new(yaml.Node)
+	if err := node.Encode(encoded); err != nil {
+		return fmt.Errorf("edit: encoding value for %q: %w", key, err)
+	}
+
+	idx, old := findMappingKey(mapping, last.names)
+	if old == nil {
+		mapping.Content = append(mapping.Content, yamlKeyNode(last.write), node)
+	} else {
+		acceptKeyNode(mapping.Content[idx], last.write)
+		node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		if old.Kind == node.Kind {
+			node.Style |= old.Style & yaml.FlowStyle
+		}
+		*old = *node
+	}
+
+	if err := c.saveYAMLDocument(doc); err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+	return nil
+}
+
+// UnsetKey removes a dotted key, or a whole section, from the YAML config
+// file and writes the file back atomically, keeping the rest of the
+// document as it is. The error wraps ErrKeyNotFound when the key is not set.
+func (c *Config) UnsetKey(s any, key string) error {
+	steps, err := c.resolveKey(s, key)
+	if err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+
+	doc, err := c.loadYAMLDocument()
+	if err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+
+	mapping := doc.Content[0]
+	for i, step := range steps {
+		idx, child := findMappingKey(mapping, step.names)
+		if child == nil {
+			return fmt.Errorf("edit: %q: %w", key, ErrKeyNotFound)
+		}
+
+		if i == len(steps)-1 {
+			mapping.Content = append(mapping.Content[:idx], mapping.Content[idx+2:]...)
+			break
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("edit: %q: %w", key, ErrKeyNotFound)
+		}
+		mapping = child
+	}
+
+	if err := c.saveYAMLDocument(doc); err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the value of the field named by a dotted key, such as
+// server.port, in s, encoded as WriteFile would write it: durations become
+// strings, slices []any and structs map[string]any. It returns nil for nil
+// pointers.
+func (c *Config) Lookup(s any, key string) (any, error) {
+	steps, err := c.resolveKey(s, key)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+
+	fv, _ := structValueFromPointer(s)
+	for _, step := range steps {
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				return nil, nil
+			}
+			fv = fv.Elem()
+		}
+		fv = fv.FieldByIndex(step.fm.index)
+	}
+
+	value, _, err := c.encodeValue(fv, c.cfgType.tagName())
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %q: %w", key, err)
+	}
+	return value, nil
+}
+
+// resolveKey maps the segments of a dotted key to the fields of s.
+func (c *Config) resolveKey(s any, key string) ([]keyStep, error) {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" {
+		return nil, fmt.Errorf("empty key")
+	}
+
+	segments := strings.Split(key, ".")
+	steps := make([]keyStep, 0, len(segments))
+	rt := rv.Type()
+	for i, segment := range segments {
+		if rt == nil {
+			return nil, fmt.Errorf("key %q: %q is not a section", key, strings.Join(segments[:i], "."))
+		}
+
+		step, ok, err := c.matchKeySegment(rt, segment)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			unknown := strings.Join(segments[:i+1], ".")
+			return nil, fmt.Errorf("key %q: unknown key %q: %w%s", key, unknown, ErrKeyNotFound, didYouMean(c.keySuggestions(rv.Type(), unknown, tagYAML)))
+		}
+		steps = append(steps, step)
+
+		rt = nil
+		if nested, ok := nestedStructType(step.typ); ok && step.typ != timeDurationType {
+			rt = nested
+		}
+	}
+
+	return steps, nil
+}
+
+func (c *Config) matchKeySegment(rt reflect.Type, segment string) (keyStep, bool, error) {
+	metas, err := c.getOrBuildFieldMeta(rt)
+	if err != nil {
+		return keyStep{}, false, err
+	}
+
+	for _, fm := range metas {
+		write := fileKeyForField(fm, tagYAML)
+		if write == "" {
+			continue
+		}
+
+		names := append([]string{write}, settingKeysForField(fm, tagYAML)...)
+		for _, name := range names {
+			if strings.EqualFold(name, segment) {
+				typ := rt.FieldByIndex(fm.index).Type
+				for typ.Kind() == reflect.Ptr {
+					typ = typ.Elem()
+				}
+				return keyStep{names: names, write: write, fm: fm, typ: typ}, true, nil
+			}
+		}
+	}
+	return keyStep{}, false, nil
+}
+
+// loadYAMLDocument parses the config file into a node tree, or returns an
+// empty document when the file does not exist.
+func (c *Config) loadYAMLDocument() (*yaml.Node, error) {
+	if c.cfgType != ConfigTypeYAML {
+		return nil, fmt.Errorf("only YAML files can be edited in place, not %s", c.cfgType)
+	}
+
+	path := c.Path()
+	if path == "" {
+		return nil, fmt.Errorf("Create or Resolve must be called first")
+	}
+
+	data, err := afero.ReadFile(c.fs, path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	doc := new(yaml.Node)
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", path, err)
+	}
+
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%q does not hold a YAML mapping", path)
+	}
+	return doc, nil
+}
+
+func (c *Config) saveYAMLDocument(doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return c.writeAtomic(c.Path(), buf.Bytes(), true)
+}
+
+// findMappingKey returns the index of the key node in mapping matching one
+// of names case-insensitively, and its value node.
+func findMappingKey(mapping *yaml.Node, names []string) (int, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		for _, name := range names {
+			if strings.EqualFold(mapping.Content[i].Value, name) {
+				return i, mapping.Content[i+1]
+			}
+		}
+	}
+	return -1, nil
+}
+
+// acceptKeyNode respells key as write unless they differ only in case, so
+// that an edited key is one the strict decoder of ReadFile accepts.
+func acceptKeyNode(key *yaml.Node, write string) {
+	if !strings.EqualFold(key.Value, write) {
+		key.Value = write
+		key.Style = 0
+	}
+}
+
+func yamlKeyNode(key string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+}
@@ -0,0 +1,61 @@
+package autoconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// fileEnvSuffix is appended to an env var name to point at a file holding
+// the value, as in MYAPP_DB_PASSWORD_FILE=/run/secrets/db.
+const fileEnvSuffix = "_FILE"
+
+// SetFileEnv enables the <PREFIX>_<KEY>_FILE convention for every field read
+// by ReadEnv. Individual fields can opt in with config:"file" instead.
+//
+// When <PREFIX>_<KEY> is absent or empty and <PREFIX>_<KEY>_FILE names a file,
+// the file contents with one trailing newline removed become the value of
+// the field, which then counts as present.
+func (c *Config) SetFileEnv(enabled bool) {
+	c.mu.Lock()
+	c.fileEnv = enabled
+	c.mu.Unlock()
+}
+
+func (c *Config) fileEnvEnabled(fm fieldMeta) bool {
+	if fm.fileEnv {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fileEnv
+}
+
+// readFileEnv returns the value referenced by the _FILE variant of the env
+// var bound to fm. ok is false when the plain variable is set or no _FILE
+// variable exists.
+func (c *Config) readFileEnv(fm fieldMeta) (value string, ok bool, err error) {
+	name := strings.ToUpper(envFieldName(c.envPrefix, fm.mapTag))
+	if v, set := os.LookupEnv(name); set && v != "" {
+		return "", false, nil
+	}
+
+	fileVar := name + fileEnvSuffix
+	path, set := os.LookupEnv(fileVar)
+	if !set || path == "" {
+		return "", false, nil
+	}
+
+	data, err := afero.ReadFile(c.fs, path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: unable to read %q: %w", fileVar, path, err)
+	}
+
+	value = string(data)
+	if trimmed, found := strings.CutSuffix(value, "\n"); found {
+		value = strings.TrimSuffix(trimmed, "\r")
+	}
+	return value, true, nil
+}
@@ -0,0 +1,337 @@
+package autoconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ErrKeyNotFound is wrapped by the key functions when a dotted key does not
+// name a field of the struct, and by UnsetKey when it is not set in the file.
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrUnknownKey is matched by UnknownKeyError: a config file key or
+// environment variable that is set but matches no field.
+var ErrUnknownKey = errors.New("unknown key")
+
+// ErrInvalidValue is wrapped by SetKey when the value cannot be converted to
+// the field type. DecodeError matches it.
+var ErrInvalidValue = errors.New("invalid value")
+
+// ErrMissingField is matched by MissingFieldError.
+var ErrMissingField = errors.New("missing required field")
+
+// ErrInvalidTag is matched by TagError.
+var ErrInvalidTag = errors.New("invalid config tag")
+
+// ErrParse is matched by ParseError.
+var ErrParse = errors.New("config syntax error")
+
+// MissingFieldError reports a required field that no source set.
+type MissingFieldError struct {
+	// Field is the path of the field in the struct, such as Database.Host.
+	Field string
+	// EnvVar is the environment variable that sets the field, if any.
+	EnvVar string
+	// Key is the dotted config file key of the field, such as database.host.
+	Key string
+	// File, Line and Column locate the closest enclosing section of Key in
+	// the files read by ReadFile, such as database:, when there is one.
+	File         string
+	Line, Column int
+}
+
+func (e *MissingFieldError) Error() string {
+	msg := fmt.Sprintf("missing required %q for field %q", e.EnvVar, e.Field)
+	if e.EnvVar == "" {
+		msg = fmt.Sprintf("missing required field %q", e.Field)
+	}
+	if e.File == "" {
+		return msg
+	}
+	return formatPosition(e.File, e.Line, e.Column) + ": " + msg
+}
+
+func (e *MissingFieldError) Is(target error) bool {
+	return target == ErrMissingField
+}
+
+// TagError reports a config struct tag that cannot be parsed.
+type TagError struct {
+	// Type is the struct type declaring the field.
+	Type string
+	// Field is the Go name of the field.
+	Field string
+	// Token is the offending part of the tag.
+	Token string
+	// Reason describes what is wrong with Token.
+	Reason string
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("config tag on field %q: %s %q", e.Field, e.Reason, e.Token)
+}
+
+func (e *TagError) Is(target error) bool {
+	return target == ErrInvalidTag
+}
+
+// DecodeError reports a value that cannot be converted to the type of the
+// field it sets.
+type DecodeError struct {
+	// Source names where the value came from: a file name, "env" or
+	// "default". It is empty when no file or document sets the key, as for
+	// values from dotenv files or overrides.
+	Source string
+	// Key is the dotted file key, the environment variable or, for defaults,
+	// the field path.
+	Key string
+	// Expected is the Go type of the field.
+	Expected string
+	// Line and Column locate the value in YAML and JSON files.
+	Line, Column int
+	Err          error
+}
+
+func (e *DecodeError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Key, e.Err)
+	if e.Source == "" {
+		return msg
+	}
+	return formatPosition(e.Source, e.Line, e.Column) + ": " + msg
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrInvalidValue
+}
+
+// UnknownKeyError reports a config file key, or with Source "env" an
+// environment variable, that matches no field.
+type UnknownKeyError struct {
+	// Source is the name of the file that sets the key. It is empty when no
+	// file or document sets it.
+	Source string
+	// Key is the dotted key, such as database.hots.
+	Key string
+	// Line and Column locate the key in YAML and JSON files.
+	Line, Column int
+	// Suggestions are the closest valid keys of the same section, best
+	// first, such as database.host.
+	Suggestions []string
+}
+
+func (e *UnknownKeyError) Error() string {
+	msg := fmt.Sprintf("unknown key %q%s", e.Key, didYouMean(e.Suggestions))
+	if e.Source == "" {
+		return msg
+	}
+	return formatPosition(e.Source, e.Line, e.Column) + ": " + msg
+}
+
+func (e *UnknownKeyError) Is(target error) bool {
+	return target == ErrUnknownKey
+}
+
+// FileNotFoundError reports a mandatory config file that does not exist. It
+// matches fs.ErrNotExist.
+type FileNotFoundError struct {
+	// Paths are the files that were tried.
+	Paths []string
+}
+
+func (e *FileNotFoundError) Error() string {
+	if len(e.Paths) == 1 {
+		return fmt.Sprintf("missing %q: %v", e.Paths[0], fs.ErrNotExist)
+	}
+	quoted := make([]string, len(e.Paths))
+	for i, path := range e.Paths {
+		quoted[i] = strconv.Quote(path)
+	}
+	return fmt.Sprintf("no config file found among %s: %v", strings.Join(quoted, ", "), fs.ErrNotExist)
+}
+
+func (e *FileNotFoundError) Unwrap() error {
+	return fs.ErrNotExist
+}
+
+// ParseError reports a syntax error in a config document. Line and Column
+// are 1-based and zero when the parser does not report them. Err is the
+// underlying viper.ConfigParseError.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid config file %s: %v", formatPosition(e.File, e.Line, e.Column), e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == ErrParse
+}
+
+// formatPosition renders file:line:col, leaving out unknown parts.
+func formatPosition(file string, line, col int) string {
+	if line <= 0 {
+		return file
+	}
+	if col <= 0 {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return fmt.Sprintf("%s:%d:%d", file, line, col)
+}
+
+var parseLineRegex = regexp.MustCompile(`\bline (\d+)\b`)
+
+// newParseError locates err, returned while parsing data, in the document.
+func newParseError(file string, data []byte, err error) *ParseError {
+	pe := &ParseError{File: file, Err: err}
+
+	var jsonErr *json.SyntaxError
+	var tomlErr *toml.DecodeError
+	switch {
+	case errors.As(err, &jsonErr):
+		pe.Line, pe.Column = offsetPosition(data, int(jsonErr.Offset))
+	case errors.As(err, &tomlErr):
+		pe.Line, pe.Column = tomlErr.Position()
+	default:
+		// YAML and the INI and properties codecs report "line N".
+		if m := parseLineRegex.FindStringSubmatch(err.Error()); m != nil {
+			pe.Line, _ = strconv.Atoi(m[1])
+		}
+	}
+	return pe
+}
+
+// offsetPosition converts a byte offset in data to a 1-based line and column.
+func offsetPosition(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line, col := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+	return line, col
+}
+
+// keyOrigin is where the value of a key came from.
+type keyOrigin struct {
+	source string
+	// key is the name reported for the key, such as an environment variable.
+	key string
+	pos keyPosition
+	// tagName is the struct tag of the source's format.
+	tagName string
+}
+
+// decodeErrors converts the mapstructure errors in err, raised while
+// decoding into rt, to UnknownKeyError and DecodeError values. locate finds
+// the origin of a dotted key. Errors it does not recognise are kept as they
+// are.
+func (c *Config) decodeErrors(rt reflect.Type, err error, locate func(key string) keyOrigin) error {
+	var errs []error
+	var walk func(err error)
+	walk = func(err error) {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				walk(e)
+			}
+			return
+		}
+
+		de, ok := err.(*mapstructure.DecodeError)
+		if !ok {
+			// Viper wraps the joined errors in a summary message.
+			var inner *mapstructure.DecodeError
+			if next := errors.Unwrap(err); next != nil && errors.As(next, &inner) {
+				walk(next)
+				return
+			}
+			errs = append(errs, err)
+			return
+		}
+
+		cause := de.Unwrap()
+		var nested *mapstructure.DecodeError
+		if errors.As(cause, &nested) {
+			walk(cause)
+			return
+		}
+
+		name := strings.ToLower(de.Name())
+		if de.Name() == rt.String() {
+			name = ""
+		}
+
+		if unknown, ok := strings.CutPrefix(cause.Error(), "has invalid keys: "); ok {
+			for _, key := range strings.Split(unknown, ", ") {
+				if name != "" {
+					key = name + "." + key
+				}
+				origin := locate(key)
+				errs = append(errs, &UnknownKeyError{
+					Source:      origin.source,
+					Key:         origin.key,
+					Line:        origin.pos.line,
+					Column:      origin.pos.col,
+					Suggestions: c.keySuggestions(rt, key, origin.tagName),
+				})
+			}
+			return
+		}
+
+		origin := locate(name)
+		decodeErr := &DecodeError{Source: origin.source, Key: origin.key, Line: origin.pos.valueLine, Column: origin.pos.valueCol, Err: cause}
+		var parseErr *mapstructure.ParseError
+		var typeErr *mapstructure.UnconvertibleTypeError
+		switch {
+		case errors.As(cause, &parseErr):
+			decodeErr.Expected = parseErr.Expected.Type().String()
+		case errors.As(cause, &typeErr):
+			decodeErr.Expected = typeErr.Expected.Type().String()
+		}
+		errs = append(errs, decodeErr)
+	}
+	walk(err)
+
+	return errors.Join(errs...)
+}
+
+// prefixErrors wraps every error joined in err with prefix, so that each
+// line of the message names the operation.
+func prefixErrors(prefix string, err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+	errs := joined.Unwrap()
+	wrapped := make([]error, len(errs))
+	for i, e := range errs {
+		wrapped[i] = fmt.Errorf("%s: %w", prefix, e)
+	}
+	return errors.Join(wrapped...)
+}
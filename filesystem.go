@@ -0,0 +1,66 @@
+package autoconfig
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// SetFs makes every file operation of c go through fsys: directory creation,
+// config files, drop-ins, dotenv and key directories, secret files and the
+// lookups of Resolve, Discover and the XDG strategy. The default is the OS
+// filesystem. Use afero.NewMemMapFs for tests.
+func (c *Config) SetFs(fsys afero.Fs) {
+	if fsys == nil {
+		fsys = afero.NewOsFs()
+	}
+	c.fs = fsys
+	c.v.SetFs(fsys)
+}
+
+// SetReadOnlyFS serves all file reads from fsys, such as an embed.FS bundle.
+// Absolute paths are looked up with the leading separator removed, so
+// /etc/myapp/config.yaml is read as etc/myapp/config.yaml. Writes and
+// EnsureDir fail.
+func (c *Config) SetReadOnlyFS(fsys fs.FS) {
+	c.SetFs(afero.NewReadOnlyFs(afero.FromIOFS{FS: rootedFS{fsys: fsys}}))
+}
+
+// SetHomeDir replaces the home directory used by Resolve, Discover and the
+// XDG strategy. An empty dir restores os.UserHomeDir.
+func (c *Config) SetHomeDir(dir string) error {
+	if dir == "" {
+		c.home = ""
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("config init: home directory %q must be an absolute path", dir)
+	}
+	c.home = filepath.Clean(dir)
+	return nil
+}
+
+func (c *Config) homeDir() (string, error) {
+	if c.home != "" {
+		return c.home, nil
+	}
+	return os.UserHomeDir()
+}
+
+// rootedFS adapts the unrooted names of io/fs to the absolute paths used by
+// Config.
+type rootedFS struct {
+	fsys fs.FS
+}
+
+func (r rootedFS) Open(name string) (fs.File, error) {
+	name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "/")
+	if name == "" {
+		name = "."
+	}
+	return r.fsys.Open(name)
+}
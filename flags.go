@@ -0,0 +1,234 @@
+package autoconfig
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// flagTarget maps one command-line flag to the fields it populates. Nested
+// fields sharing a mapstructure key share a flag, as they share an env var.
+// keys[i] is the dotted setting key of the field at paths[i].
+type flagTarget struct {
+	keys  []string
+	paths [][]string
+}
+
+// fieldFlag is a flag.Value that validates input against the field type
+// using the same conversions as config:"default=..." values.
+type fieldFlag struct {
+	typ    reflect.Type
+	field  string
+	value  string
+	isBool bool
+}
+
+func (f *fieldFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *fieldFlag) Set(raw string) error {
+	if err := setValueFromString(reflect.New(f.typ).Elem(), f.field, "value", raw); err != nil {
+		return err
+	}
+	f.value = raw
+	return nil
+}
+
+func (f *fieldFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// ReadFlags parses args with flags derived from s and applies the flags that
+// were passed. Flags take precedence over environment variables and files.
+// Use RegisterFlags and ApplyFlags to keep control of the flag.FlagSet.
+func (c *Config) ReadFlags(s any, args []string) error {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	if err := c.RegisterFlags(fs, s); err != nil {
+		return err
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+
+	return c.ApplyFlags(fs, s)
+}
+
+// RegisterFlags defines a flag on fs for every field of s that binds to an
+// environment variable. Flag names are the lower-cased mapstructure keys with
+// underscores replaced by dashes, so PORT becomes -port and FEATURES_ENABLED
+// becomes -features-enabled. Names already defined on fs are left untouched.
+func (c *Config) RegisterFlags(fs *flag.FlagSet, s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("register flags: %w", err)
+	}
+
+	err = c.walkEnvFields(rv, c.rootPathForType(rv.Type()), func(fm fieldMeta, fieldPath []string, field reflect.Value) error {
+		if fm.isStruct {
+			return nil
+		}
+
+		ft := flagFieldType(field)
+		name := flagName(fm.mapTag)
+		if ft == nil || fs.Lookup(name) != nil {
+			return nil
+		}
+
+		value := &fieldFlag{typ: ft, field: fm.name, isBool: ft.Kind() == reflect.Bool}
+		if fm.defaultVal != nil {
+			if err := value.Set(*fm.defaultVal); err != nil {
+				return err
+			}
+		}
+
+		fs.Var(value, name, c.flagUsage(fm, fieldPath))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("register flags: %w", err)
+	}
+	return nil
+}
+
+// ApplyFlags copies the flags of fs that were set on the command line into
+// the configuration, records them as present and unmarshals into s.
+func (c *Config) ApplyFlags(fs *flag.FlagSet, s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+
+	targets, err := c.flagTargets(rv)
+	if err != nil {
+		return fmt.Errorf("read flags: %w", err)
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		target, ok := targets[f.Name]
+		if !ok {
+			return
+		}
+
+		for i, path := range target.paths {
+			c.v.Set(target.keys[i], f.Value.String())
+			c.recordFlagKey(target.keys[i])
+			c.recordPresence(path)
+		}
+	})
+
+	if err := c.v.Unmarshal(s, decoderOptions()...); err != nil {
+		return fmt.Errorf("read flags: error unmarshaling: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Config) flagTargets(rv reflect.Value) (map[string]*flagTarget, error) {
+	targets := make(map[string]*flagTarget)
+	err := c.walkEnvFields(rv, c.rootPathForType(rv.Type()), func(fm fieldMeta, fieldPath []string, field reflect.Value) error {
+		if fm.isStruct || flagFieldType(field) == nil {
+			return nil
+		}
+
+		key, err := c.settingKey(rv.Type(), fieldPath)
+		if err != nil {
+			return err
+		}
+
+		name := flagName(fm.mapTag)
+		target, ok := targets[name]
+		if !ok {
+			target = &flagTarget{}
+			targets[name] = target
+		}
+		target.keys = append(target.keys, key)
+		target.paths = append(target.paths, fieldPath)
+		return nil
+	})
+	return targets, err
+}
+
+// recordFlagKey remembers that ApplyFlags set key, so that dotenv and _FILE
+// values, which share the override layer, do not replace it.
+func (c *Config) recordFlagKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flagKeys[strings.ToLower(key)] = struct{}{}
+}
+
+// settingKey returns the dotted key under which the decoder looks up the
+// field at fieldPath below rt, such as log.level for Log.Level. Flags are
+// set under this key so that nested fields receive them.
+func (c *Config) settingKey(rt reflect.Type, fieldPath []string) (string, error) {
+	segments := make([]string, 0, len(fieldPath))
+	for i, name := range fieldPath[1:] {
+		metas, err := c.getOrBuildFieldMeta(rt)
+		if err != nil {
+			return "", err
+		}
+
+		var fm fieldMeta
+		for _, meta := range metas {
+			if meta.name == name {
+				fm = meta
+				break
+			}
+		}
+		if fm.name == "" {
+			return "", fmt.Errorf("field %q not found in %s", name, rt)
+		}
+
+		segments = append(segments, fileKeyForField(fm, ""))
+
+		if i < len(fieldPath)-2 {
+			nested, ok := nestedStructType(rt.FieldByIndex(fm.index).Type)
+			if !ok {
+				return "", fmt.Errorf("field %q of %s is not a struct", name, rt)
+			}
+			rt = nested
+		}
+	}
+	return strings.Join(segments, "."), nil
+}
+
+func (c *Config) flagUsage(fm fieldMeta, fieldPath []string) string {
+	usage := fmt.Sprintf("%s (env %s)", fieldDisplayPath(fieldPath), envFieldName(c.envPrefix, fm.mapTag))
+	if fm.required {
+		usage += ", required"
+	}
+	return usage
+}
+
+// flagFieldType returns the dereferenced type of field, or nil when the type
+// cannot be set from a single command-line value.
+func flagFieldType(field reflect.Value) reflect.Type {
+	ft := field.Type()
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+
+	switch ft.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return ft
+	case reflect.Slice:
+		if ft.Elem().Kind() == reflect.String {
+			return ft
+		}
+	}
+	return nil
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "_", "-")
+}
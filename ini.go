@@ -0,0 +1,183 @@
+package autoconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// iniCodec decodes and encodes INI documents. Keys outside a section are
+// top-level settings; "[a.b]" sections become nested maps.
+type iniCodec struct{}
+
+func (iniCodec) Decode(data []byte) (map[string]any, error) {
+	v := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	section := []string(nil)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		switch {
+		case line == "", strings.HasPrefix(line, ";"), strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("ini: line %d: unterminated section header %q", lineNo, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("ini: line %d: empty section name", lineNo)
+			}
+			section = splitKeyPath(name)
+			if _, err := nestedMap(v, section); err != nil {
+				return nil, fmt.Errorf("ini: line %d: %w", lineNo, err)
+			}
+			continue
+		}
+
+		idx := strings.IndexAny(line, "=:")
+		if idx <= 0 {
+			return nil, fmt.Errorf("ini: line %d: expected key = value, got %q", lineNo, line)
+		}
+
+		key := strings.TrimSpace(line[:idx])
+		value := unquoteINIValue(strings.TrimSpace(line[idx+1:]))
+
+		if err := setNestedValue(v, append(append([]string(nil), section...), key), value); err != nil {
+			return nil, fmt.Errorf("ini: line %d: %w", lineNo, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ini: %w", err)
+	}
+	return v, nil
+}
+
+func (iniCodec) Encode(settings map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	writeINISection(&buf, nil, settings)
+	return buf.Bytes(), nil
+}
+
+func (iniCodec) Extensions() []string { return []string{"ini"} }
+func (iniCodec) TagName() string      { return tagINI }
+
+func writeINISection(buf *bytes.Buffer, section []string, m map[string]any) {
+	keys := sortedKeys(m)
+
+	var nested []string
+	wroteHeader := len(section) == 0
+	for _, key := range keys {
+		if _, ok := toStringAnyMap(m[key]); ok {
+			nested = append(nested, key)
+			continue
+		}
+		if !wroteHeader {
+			if buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			fmt.Fprintf(buf, "[%s]\n", strings.Join(section, "."))
+			wroteHeader = true
+		}
+		fmt.Fprintf(buf, "%s = %s\n", key, formatFlatValue(m[key]))
+	}
+
+	for _, key := range nested {
+		child, _ := toStringAnyMap(m[key])
+		writeINISection(buf, appendPath(section, key), child)
+	}
+}
+
+func unquoteINIValue(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' && last == '"') || (first == '\'' && last == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// splitKeyPath splits a dotted key into its trimmed path elements.
+func splitKeyPath(key string) []string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts
+}
+
+// nestedMap returns the map at path inside m, creating intermediate maps.
+func nestedMap(m map[string]any, path []string) (map[string]any, error) {
+	current := m
+	for i, elem := range path {
+		next, found := current[elem]
+		if !found {
+			child := make(map[string]any)
+			current[elem] = child
+			current = child
+			continue
+		}
+
+		child, ok := toStringAnyMap(next)
+		if !ok {
+			return nil, fmt.Errorf("key %q is both a value and a table", strings.Join(path[:i+1], "."))
+		}
+		current = child
+	}
+	return current, nil
+}
+
+// setNestedValue stores value at path inside m, creating intermediate maps.
+func setNestedValue(m map[string]any, path []string, value any) error {
+	parent, err := nestedMap(m, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	last := path[len(path)-1]
+	if existing, found := parent[last]; found {
+		if _, ok := toStringAnyMap(existing); ok {
+			return fmt.Errorf("key %q is both a value and a table", strings.Join(path, "."))
+		}
+	}
+	parent[last] = value
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFlatValue renders a setting value for line-oriented formats. Lists are
+// joined with commas so they decode back through stringToStringSliceHookFunc.
+func formatFlatValue(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case []any:
+		items := make([]string, 0, len(typed))
+		for _, item := range typed {
+			items = append(items, formatFlatValue(item))
+		}
+		return strings.Join(items, ",")
+	case []string:
+		return strings.Join(typed, ",")
+	default:
+		return fmt.Sprint(typed)
+	}
+}
@@ -0,0 +1,173 @@
+package autoconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// kubernetesDataDir is the symlink Kubernetes swaps atomically when a
+// mounted ConfigMap or Secret changes. The visible key files point into it.
+const kubernetesDataDir = "..data"
+
+// ReadKeyDir reads a directory holding one file per key, as exposed by
+// Kubernetes ConfigMap and Secret volumes or systemd's
+// $CREDENTIALS_DIRECTORY, and unmarshals the values into s.
+//
+// A file name matches a field's mapstructure key, or the field name when the
+// field has none, case-insensitively. Fields of config:"struct" structs use
+// the dotted path, such as features.enabled. File contents with one trailing
+// newline removed are decoded with the same conversions as config files, and
+// matched fields count as present once every value decodes; when one fails,
+// none of them is applied. Files that match no field are ignored.
+//
+// Values are merged over the config files read so far, so call ReadKeyDir
+// after ReadFile; environment variables and flags still take precedence.
+// Hidden files are skipped, and when the directory has a ..data entry the
+// keys are read through it so that one consistent revision is used.
+func (c *Config) ReadKeyDir(dir string, s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("read key directory: %w", err)
+	}
+
+	files, err := c.keyDirFiles(dir)
+	if err != nil {
+		return fmt.Errorf("read key directory: %w", err)
+	}
+
+	settings := make(map[string]any)
+	var present [][]string
+	err = c.walkKeyDir(rv, c.rootPathForType(rv.Type()), nil, func(key []string, fieldPath []string) error {
+		path, ok := files[strings.Join(key, ".")]
+		if !ok {
+			return nil
+		}
+
+		data, err := afero.ReadFile(c.fs, path)
+		if err != nil {
+			return fmt.Errorf("unable to read %q: %w", path, err)
+		}
+
+		value := string(data)
+		if trimmed, found := strings.CutSuffix(value, "\n"); found {
+			value = strings.TrimSuffix(trimmed, "\r")
+		}
+
+		if err := setNestedValue(settings, key, value); err != nil {
+			return err
+		}
+		present = append(present, fieldPath)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read key directory: %w", err)
+	}
+
+	if err := decodeExact(rv.Type(), settings); err != nil {
+		return fmt.Errorf("read key directory %q: %w", dir, err)
+	}
+	if err := c.v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("read key directory: merging %q: %w", dir, err)
+	}
+	c.keepLayers(loadedSource{name: dir, settings: settings})
+
+	// Presence is recorded only once the values decoded and merged. The
+	// enclosing structs of a matched field count as present, like nested
+	// sections of a config file.
+	for _, fieldPath := range present {
+		for i := 2; i <= len(fieldPath); i++ {
+			c.recordPresence(fieldPath[:i])
+		}
+	}
+
+	if err := c.v.Unmarshal(s, decoderOptions()...); err != nil {
+		return fmt.Errorf("read key directory: error unmarshaling: %w", err)
+	}
+
+	return nil
+}
+
+// walkKeyDir calls fn with the lower-cased key path of every leaf field of
+// rv, recursing into config:"struct" fields.
+func (c *Config) walkKeyDir(rv reflect.Value, path, key []string, fn func(key []string, fieldPath []string) error) error {
+	metas, err := c.getOrBuildFieldMeta(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, fm := range metas {
+		name := fm.mapTag
+		if name == "" {
+			name = fm.name
+		}
+		if name == "-" {
+			continue
+		}
+
+		fieldKey := append(key[:len(key):len(key)], strings.ToLower(name))
+		fieldPath := appendPath(path, fm.name)
+
+		if fm.isStruct {
+			nested, ok := ensureStructValue(rv.FieldByIndex(fm.index))
+			if !ok {
+				continue
+			}
+
+			if err := c.walkKeyDir(nested, fieldPath, fieldKey, fn); err != nil {
+				return fmt.Errorf("nested struct %q: %w", fm.name, err)
+			}
+			continue
+		}
+
+		if err := fn(fieldKey, fieldPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keyDirFiles maps lower-cased file names in dir to their paths.
+func (c *Config) keyDirFiles(dir string) (map[string]string, error) {
+	if info, err := c.fs.Stat(filepath.Join(dir, kubernetesDataDir)); err == nil && info.IsDir() {
+		dir = filepath.Join(dir, kubernetesDataDir)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	entries, err := afero.ReadDir(c.fs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		info, err := c.fs.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		key := strings.ToLower(name)
+		if other, ok := files[key]; ok {
+			return nil, fmt.Errorf("files %q and %q map to the same key", filepath.Base(other), name)
+		}
+		files[key] = path
+	}
+
+	return files, nil
+}
@@ -0,0 +1,91 @@
+package autoconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Location selects where Create places the config file when baseDirname is
+// empty.
+type Location uint8
+
+const (
+	// LocationHome uses ~/.<project>/. It is the default.
+	LocationHome Location = iota
+	// LocationXDG follows the XDG base directory specification: the file lives
+	// in $XDG_CONFIG_HOME/<project>/ (~/.config/<project>/ when unset), and
+	// ReadFile falls back to <dir>/<project>/ for each dir in $XDG_CONFIG_DIRS
+	// (/etc/xdg when unset) while the user file does not exist.
+	LocationXDG
+)
+
+// SetLocation selects the location strategy used by the next Create call.
+func (c *Config) SetLocation(loc Location) {
+	c.location = loc
+}
+
+// LegacyFile returns the path of a config file in the ~/.<project>/
+// directory when Create resolved an XDG location and that file exists, so
+// that callers can ask users to migrate it. It returns "" otherwise.
+func (c *Config) LegacyFile() string {
+	return c.legacyPath
+}
+
+// resolveXDG sets up the XDG location for the project below home.
+func (c *Config) resolveXDG(home, project string) error {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" || !filepath.IsAbs(configHome) {
+		configHome = filepath.Join(home, ".config")
+	}
+	configHome = filepath.Clean(configHome)
+
+	c.dirname = filepath.Join(configHome, project)
+	if !isWithinBaseDir(configHome, c.dirname) {
+		return fmt.Errorf("resolved config directory %q escapes %q", c.dirname, configHome)
+	}
+
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = filepath.Join(string(filepath.Separator), "etc", "xdg")
+	}
+	for _, dir := range filepath.SplitList(configDirs) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		candidate := filepath.Join(dir, project)
+		if isWithinBaseDir(dir, candidate) {
+			c.searchDirs = append(c.searchDirs, candidate)
+		}
+	}
+
+	legacy := filepath.Join(home, "."+project, c.cfgBaseName+"."+c.cfgType.extension())
+	if isWithinBaseDir(home, legacy) && c.fileExists(legacy) {
+		c.legacyPath = legacy
+	}
+	return nil
+}
+
+// searchedFilePath returns the first existing file among the resolved path
+// and the search directories, or the resolved path when none exists.
+func (c *Config) searchedFilePath() string {
+	path := c.configFilePath()
+	if c.cfgPath != "" || c.fileExists(path) {
+		return path
+	}
+
+	name := filepath.Base(path)
+	for _, dir := range c.searchDirs {
+		candidate := filepath.Join(dir, name)
+		if c.fileExists(candidate) {
+			return candidate
+		}
+	}
+	return path
+}
+
+func (c *Config) fileExists(path string) bool {
+	info, err := c.fs.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,150 @@
+package autoconfig
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/pflag"
+)
+
+// boundPFlag remembers a pflag bound by BindPFlags and the fields it sets.
+// keys[i] is the dotted setting key the flag is bound to for paths[i].
+type boundPFlag struct {
+	flag  *pflag.Flag
+	keys  []string
+	paths [][]string
+}
+
+// Type implements pflag.Value.
+func (f *fieldFlag) Type() string {
+	switch {
+	case f.typ == timeDurationType:
+		return "duration"
+	case f.typ.Kind() == reflect.Slice:
+		return "strings"
+	case f.typ.Kind() == reflect.Float32 || f.typ.Kind() == reflect.Float64:
+		return "float"
+	default:
+		return f.typ.Kind().String()
+	}
+}
+
+// BindPFlags defines a flag on fs for every field of s that binds to an
+// environment variable and binds it into the configuration. Names follow
+// RegisterFlags; default= values become flag defaults and required fields are
+// marked in the usage text. For cobra commands pass cmd.PersistentFlags().
+//
+// Bound flags that were set on the command line take precedence over
+// environment variables and files in ReadFile and ReadEnv. Call ApplyPFlags
+// after parsing to record them as present and unmarshal them into s.
+//
+// Required flags are not enforced by the flag parser: an environment
+// variable or file value satisfies them, and Check reports what is missing.
+func (c *Config) BindPFlags(fs *pflag.FlagSet, s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("bind pflags: %w", err)
+	}
+
+	bound := make(map[string]*boundPFlag)
+	order := make([]string, 0)
+	err = c.walkEnvFields(rv, c.rootPathForType(rv.Type()), func(fm fieldMeta, fieldPath []string, field reflect.Value) error {
+		ft := flagFieldType(field)
+		if fm.isStruct || ft == nil {
+			return nil
+		}
+
+		// Viper decodes the bound flag's default with the other settings, so
+		// the flag must be bound under the key the field is decoded from.
+		key, err := c.settingKey(rv.Type(), fieldPath)
+		if err != nil {
+			return err
+		}
+
+		name := flagName(fm.mapTag)
+		f := fs.Lookup(name)
+		if f == nil {
+			value := &fieldFlag{typ: ft, field: fm.name, isBool: ft.Kind() == reflect.Bool}
+			raw := zeroFlagValue(ft)
+			if fm.defaultVal != nil {
+				raw = *fm.defaultVal
+			}
+			if err := value.Set(raw); err != nil {
+				return err
+			}
+
+			f = fs.VarPF(value, name, "", c.flagUsage(fm, fieldPath))
+			if value.isBool {
+				f.NoOptDefVal = "true"
+			}
+		}
+
+		if err := c.v.BindPFlag(key, f); err != nil {
+			return fmt.Errorf("bind flag %q for %q: %w", name, fm.name, err)
+		}
+
+		b, ok := bound[name]
+		if !ok {
+			b = &boundPFlag{flag: f}
+			bound[name] = b
+			order = append(order, name)
+		}
+		b.keys = append(b.keys, key)
+		b.paths = append(b.paths, fieldPath)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bind pflags: %w", err)
+	}
+
+	c.mu.Lock()
+	for _, name := range order {
+		c.pflags = append(c.pflags, bound[name])
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ApplyPFlags records the flags bound by BindPFlags that were set on the
+// command line as present and unmarshals the configuration into s. Call it
+// after the flags were parsed, for example from a cobra RunE function.
+func (c *Config) ApplyPFlags(s any) error {
+	if _, err := structValueFromPointer(s); err != nil {
+		return fmt.Errorf("read pflags: %w", err)
+	}
+
+	c.mu.RLock()
+	bound := append([]*boundPFlag(nil), c.pflags...)
+	c.mu.RUnlock()
+
+	for _, b := range bound {
+		if !b.flag.Changed {
+			continue
+		}
+		for _, path := range b.paths {
+			c.recordPresence(path)
+		}
+	}
+
+	if err := c.v.Unmarshal(s, decoderOptions()...); err != nil {
+		return fmt.Errorf("read pflags: error unmarshaling: %w", err)
+	}
+
+	return nil
+}
+
+// zeroFlagValue is the textual zero value of t. Viper falls back to a bound
+// flag's value when no other source sets the key, so it must decode cleanly.
+func zeroFlagValue(t reflect.Type) string {
+	switch {
+	case t == timeDurationType:
+		return "0s"
+	case t.Kind() == reflect.Bool:
+		return "false"
+	case t.Kind() == reflect.String, t.Kind() == reflect.Slice:
+		return ""
+	default:
+		return "0"
+	}
+}
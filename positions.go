@@ -0,0 +1,62 @@
+package autoconfig
+
+import (
+	"strconv"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// keyPosition is where a key and its value appear in a document. Lines and
+// columns are 1-based.
+type keyPosition struct {
+	line, col           int
+	valueLine, valueCol int
+}
+
+// keyPositions indexes the keys of a YAML or JSON document by their dotted,
+// lower-cased path, as Viper and the decode errors name them. List elements
+// are indexed as key[i]. Other formats and documents that yaml.v3 cannot
+// parse yield nil, and errors are then reported without positions.
+func keyPositions(data []byte, t ConfigType) map[string]keyPosition {
+	if t != ConfigTypeYAML && t != ConfigTypeJSON {
+		return nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	positions := make(map[string]keyPosition)
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.AliasNode:
+			walk(node.Alias, path)
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if key.Tag == "!!merge" {
+					walk(value, path)
+					continue
+				}
+				child := strings.ToLower(key.Value)
+				if path != "" {
+					child = path + "." + child
+				}
+				positions[child] = keyPosition{line: key.Line, col: key.Column, valueLine: value.Line, valueCol: value.Column}
+				walk(value, child)
+			}
+		case yaml.SequenceNode:
+			for i, elem := range node.Content {
+				child := path + "[" + strconv.Itoa(i) + "]"
+				positions[child] = keyPosition{line: elem.Line, col: elem.Column, valueLine: elem.Line, valueCol: elem.Column}
+				walk(elem, child)
+			}
+		}
+	}
+	walk(doc.Content[0], "")
+
+	return positions
+}
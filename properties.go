@@ -0,0 +1,174 @@
+package autoconfig
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// propertiesCodec decodes and encodes Java .properties documents. Dotted keys
+// such as "server.port" become nested maps.
+type propertiesCodec struct{}
+
+func (propertiesCodec) Decode(data []byte) (map[string]any, error) {
+	v := make(map[string]any)
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if i == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		// A line ending in an odd number of backslashes continues on the next line.
+		for endsWithContinuation(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+
+		rawKey, rawValue := splitPropertiesLine(line)
+		key, err := unescapeProperties(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("properties: line %d: %w", lineNo, err)
+		}
+		value, err := unescapeProperties(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("properties: line %d: %w", lineNo, err)
+		}
+		if key == "" {
+			return nil, fmt.Errorf("properties: line %d: empty key", lineNo)
+		}
+
+		if err := setNestedValue(v, splitKeyPath(key), value); err != nil {
+			return nil, fmt.Errorf("properties: line %d: %w", lineNo, err)
+		}
+	}
+
+	return v, nil
+}
+
+func (propertiesCodec) Encode(settings map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	writeProperties(&buf, nil, settings)
+	return buf.Bytes(), nil
+}
+
+func (propertiesCodec) Extensions() []string { return []string{"properties", "props", "prop"} }
+func (propertiesCodec) TagName() string      { return tagProperties }
+
+func writeProperties(buf *bytes.Buffer, prefix []string, m map[string]any) {
+	for _, key := range sortedKeys(m) {
+		path := appendPath(prefix, key)
+		if child, ok := toStringAnyMap(m[key]); ok {
+			writeProperties(buf, path, child)
+			continue
+		}
+		fmt.Fprintf(buf, "%s = %s\n", escapeProperties(strings.Join(path, "."), true), escapeProperties(formatFlatValue(m[key]), false))
+	}
+}
+
+func endsWithContinuation(line string) bool {
+	count := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		count++
+	}
+	return count%2 == 1
+}
+
+// splitPropertiesLine splits a logical line at the first unescaped '=', ':'
+// or whitespace, as described by java.util.Properties.load.
+func splitPropertiesLine(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return line[:i], rest
+		}
+	}
+	return line, ""
+}
+
+func unescapeProperties(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch != '\\' || i+1 == len(value) {
+			b.WriteByte(ch)
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(value) {
+				return "", fmt.Errorf("truncated unicode escape in %q", value)
+			}
+			r, err := strconv.ParseUint(value[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape in %q", value)
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String(), nil
+}
+
+func escapeProperties(value string, isKey bool) string {
+	var b strings.Builder
+	b.Grow(len(value))
+	for i, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if isKey || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		case ' ':
+			if isKey || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
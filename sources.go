@@ -0,0 +1,251 @@
+package autoconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// FileSource describes one configuration file layer read by ReadFile.
+type FileSource struct {
+	// Path is the absolute path of the file.
+	Path string
+	// Type selects the codec. ConfigTypeNone infers it from the file extension.
+	Type ConfigType
+	// Optional sources are skipped when the file does not exist.
+	Optional bool
+}
+
+// loadedSource is a FileSource that was read and decoded by ReadFile.
+type loadedSource struct {
+	FileSource
+	// name labels the layer in errors.
+	name      string
+	settings  map[string]any
+	positions map[string]keyPosition
+}
+
+// SetFileSources replaces the files read by ReadFile. Sources are merged in
+// order, so values from later sources override earlier ones. Passing no
+// sources restores the single file resolved by Create.
+func (c *Config) SetFileSources(sources ...FileSource) error {
+	resolved := make([]FileSource, 0, len(sources))
+	for _, src := range sources {
+		cleaned := filepath.Clean(src.Path)
+		if src.Path == "" || !filepath.IsAbs(cleaned) {
+			return fmt.Errorf("file sources: path %q must be absolute", src.Path)
+		}
+		src.Path = cleaned
+
+		if !src.Type.IsValid() {
+			ct, ok := configTypeForExtension(filepath.Ext(cleaned))
+			if !ok {
+				return fmt.Errorf("file sources: cannot infer config type of %q", src.Path)
+			}
+			src.Type = ct
+		}
+		resolved = append(resolved, src)
+	}
+
+	c.sources = resolved
+	return nil
+}
+
+// StandardFileSources returns the conventional layers for the project set up
+// by Create, lowest precedence first:
+//
+//   - system: /etc/<project>/<name>.<ext>
+//   - user: the file resolved by Create
+//   - project: .<project>.<ext> in the working directory
+//   - override: the override path, when not empty
+//
+// All layers except the override are optional.
+func (c *Config) StandardFileSources(override string) ([]FileSource, error) {
+	if c.project == "" {
+		return nil, fmt.Errorf("file sources: Create or Resolve must be called first")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("file sources: getting working directory: %w", err)
+	}
+
+	ext := c.cfgType.extension()
+	sources := []FileSource{
+		{Path: filepath.Join(string(filepath.Separator), "etc", strings.TrimPrefix(c.project, "."), c.cfgBaseName+"."+ext), Type: c.cfgType, Optional: true},
+		{Path: c.configFilePath(), Type: c.cfgType, Optional: true},
+		{Path: filepath.Join(wd, c.project+"."+ext), Type: c.cfgType, Optional: true},
+	}
+
+	if override != "" {
+		cleaned := filepath.Clean(override)
+		if !filepath.IsAbs(cleaned) {
+			return nil, fmt.Errorf("file sources: override %q must be an absolute path", override)
+		}
+		sources = append(sources, FileSource{Path: cleaned})
+	}
+
+	return sources, nil
+}
+
+// EnableDropIns makes ReadFile merge fragments from a drop-in directory over
+// the file layers. An empty dir selects "<name>.d" next to the file resolved
+// by Create. Fragments are files with a registered extension, merged in
+// lexical order; hidden files and subdirectories are ignored. A missing
+// directory is not an error.
+func (c *Config) EnableDropIns(dir string) error {
+	if dir == "" {
+		if c.dirname == "" {
+			return fmt.Errorf("drop-ins: Create or Resolve must be called first")
+		}
+		dir = filepath.Join(c.dirname, c.cfgBaseName+".d")
+	}
+
+	cleaned := filepath.Clean(dir)
+	if !filepath.IsAbs(cleaned) {
+		return fmt.Errorf("drop-ins: directory %q must be an absolute path", dir)
+	}
+
+	c.dropInDir = cleaned
+	return nil
+}
+
+// LoadedFiles returns the paths merged by the last successful ReadFile, in
+// merge order.
+func (c *Config) LoadedFiles() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.loaded...)
+}
+
+func (c *Config) fileSources() []FileSource {
+	if len(c.sources) > 0 {
+		return c.sources
+	}
+	return []FileSource{{Path: c.searchedFilePath(), Type: c.cfgType}}
+}
+
+// dropInSources lists the fragments in the drop-in directory in lexical order.
+func (c *Config) dropInSources() ([]FileSource, error) {
+	if c.dropInDir == "" {
+		return nil, nil
+	}
+
+	entries, err := afero.ReadDir(c.fs, c.dropInDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]FileSource, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		ct, ok := configTypeForExtension(filepath.Ext(name))
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(c.dropInDir, name)
+		info, err := c.fs.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		sources = append(sources, FileSource{Path: path, Type: ct})
+	}
+	return sources, nil
+}
+
+// decodeExact strictly decodes settings into a fresh value of rt so that one
+// layer can be validated on its own.
+func decodeExact(rt reflect.Type, settings map[string]any) error {
+	lv := viper.New()
+	if err := lv.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	return lv.UnmarshalExact(reflect.New(rt).Interface(), decoderOptions()...)
+}
+
+// readFileSource reads and decodes one file.
+func (c *Config) readFileSource(src FileSource) (loadedSource, error) {
+	data, err := afero.ReadFile(c.fs, src.Path)
+	if err != nil {
+		return loadedSource{}, err
+	}
+	return loadDocument(src, src.Path, data)
+}
+
+// loadDocument decodes one document and indexes the positions of its keys.
+func loadDocument(src FileSource, name string, data []byte) (loadedSource, error) {
+	settings, err := decodeSettings(bytes.NewReader(data), src.Type)
+	if err != nil {
+		var parseErr viper.ConfigParseError
+		if errors.As(err, &parseErr) {
+			return loadedSource{}, newParseError(name, data, err)
+		}
+		return loadedSource{}, err
+	}
+	return loadedSource{FileSource: src, name: name, settings: settings, positions: keyPositions(data, src.Type)}, nil
+}
+
+// locateKey returns the origin of a dotted key, as named in decode errors, in
+// the last of layers that sets it. A key no layer sets came from another
+// source, such as a dotenv value or a flag, and is reported without one.
+func locateKey(layers []loadedSource, key string) keyOrigin {
+	for i := len(layers) - 1; i >= 0; i-- {
+		if settingsHaveKey(layers[i].settings, key) {
+			return keyOrigin{source: layers[i].name, key: key, pos: layers[i].positions[key], tagName: layers[i].Type.tagName()}
+		}
+	}
+	return keyOrigin{key: key}
+}
+
+// settingsHaveKey reports whether the dotted key, as named in decode errors,
+// is set in settings. List indexes are ignored.
+func settingsHaveKey(settings map[string]any, key string) bool {
+	if key == "" {
+		return true
+	}
+	var current any = settings
+	for _, part := range strings.Split(key, ".") {
+		part, _, _ = strings.Cut(part, "[")
+		m, ok := toStringAnyMap(current)
+		if !ok {
+			return false
+		}
+		if current, ok = m[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeSettings decodes one document. Decoding goes through a scratch Viper
+// instance so that keys are normalised exactly as in c.v and parse failures
+// surface as viper.ConfigParseError.
+func decodeSettings(r io.Reader, t ConfigType) (map[string]any, error) {
+	lv := viper.NewWithOptions(viper.WithCodecRegistry(codecRegistry{}))
+	lv.SetConfigType(t.String())
+	if err := lv.ReadConfig(r); err != nil {
+		return nil, err
+	}
+	return lv.AllSettings(), nil
+}
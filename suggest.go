@@ -0,0 +1,240 @@
+package autoconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxSuggestions caps the number of "did you mean" candidates.
+const maxSuggestions = 3
+
+// Suggest returns up to three candidates closest to name by edit distance,
+// best first, for "did you mean" hints. Case is ignored, an adjacent
+// transposition counts as one edit, and candidates more than a third of the
+// length of name away (at least one edit) are left out.
+func Suggest(name string, candidates []string) []string {
+	type scored struct {
+		candidate string
+		distance  int
+	}
+
+	limit := max(1, len(name)/3)
+	lower := strings.ToLower(name)
+	seen := make(map[string]struct{}, len(candidates))
+	matches := make([]scored, 0)
+	for _, candidate := range candidates {
+		if _, ok := seen[candidate]; ok {
+			continue
+		}
+		seen[candidate] = struct{}{}
+
+		if d := editDistance(lower, strings.ToLower(candidate)); d <= limit {
+			matches = append(matches, scored{candidate: candidate, distance: d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	out := make([]string, 0, maxSuggestions)
+	for _, m := range matches {
+		if len(out) == maxSuggestions {
+			break
+		}
+		out = append(out, m.candidate)
+	}
+	return out
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and adjacent transpositions.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d := min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d = min(d, rows[i-2][j-2]+1)
+			}
+			rows[i][j] = d
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+// didYouMean renders suggestions as a message suffix.
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = strconv.Quote(s)
+	}
+	return ", did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
+// keySuggestions returns the dotted keys of the section holding key, as
+// named in decode errors, whose last segment is closest to that of key.
+// tagName selects the struct tag for the suggested spelling.
+func (c *Config) keySuggestions(rt reflect.Type, key, tagName string) []string {
+	segments := strings.Split(key, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		name, _, _ := strings.Cut(segment, "[")
+		step, ok, err := c.matchKeySegment(rt, name)
+		if err != nil || !ok {
+			return nil
+		}
+
+		// Each [index] steps into an element of a list or map.
+		typ := step.typ
+		for range strings.Count(segment, "[") {
+			for typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			switch typ.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				typ = typ.Elem()
+			default:
+				return nil
+			}
+		}
+
+		nested, ok := nestedStructType(typ)
+		if !ok {
+			return nil
+		}
+		rt = nested
+	}
+
+	metas, err := c.getOrBuildFieldMeta(rt)
+	if err != nil {
+		return nil
+	}
+
+	// Every spelling a field accepts is a candidate, but the field is
+	// suggested under its canonical key.
+	canonical := make(map[string]string)
+	candidates := make([]string, 0, len(metas))
+	for _, fm := range metas {
+		write := fileKeyForField(fm, tagName)
+		if write == "" {
+			continue
+		}
+		for _, name := range append([]string{write}, settingKeysForField(fm, tagName)...) {
+			if _, ok := canonical[name]; !ok {
+				canonical[name] = write
+				candidates = append(candidates, name)
+			}
+		}
+	}
+
+	last, _, _ := strings.Cut(segments[len(segments)-1], "[")
+	suggestions := canonicalSuggestions(last, candidates, canonical)
+	if section := strings.Join(segments[:len(segments)-1], "."); section != "" {
+		for i, suggestion := range suggestions {
+			suggestions[i] = section + "." + suggestion
+		}
+	}
+	return suggestions
+}
+
+// canonicalSuggestions runs Suggest over candidates and maps each match to
+// its canonical name, dropping duplicates and name itself.
+func canonicalSuggestions(name string, candidates []string, canonical map[string]string) []string {
+	var out []string
+	seen := make(map[string]struct{})
+	for _, match := range Suggest(name, candidates) {
+		suggestion := canonical[match]
+		if _, ok := seen[suggestion]; ok || strings.EqualFold(suggestion, name) {
+			continue
+		}
+		seen[suggestion] = struct{}{}
+		out = append(out, suggestion)
+	}
+	return out
+}
+
+// UnknownEnv reports the environment variables that carry the env prefix but
+// set no field of s, such as MYAPP_PROT, with suggestions for the variables
+// that were probably meant. Each one is an UnknownKeyError with Source "env";
+// they are combined with errors.Join. It returns nil when every variable is
+// known or when no prefix is set.
+func (c *Config) UnknownEnv(s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("unknown env: %w", err)
+	}
+	if c.envPrefix == "" {
+		return nil
+	}
+
+	prefix := strings.ToUpper(strings.TrimSpace(c.envPrefix)) + "_"
+	canonical := make(map[string]string)
+	candidates := make([]string, 0)
+	add := func(name string) {
+		name = strings.ToUpper(name)
+		short := strings.TrimPrefix(name, prefix)
+		if _, ok := canonical[short]; !ok {
+			canonical[short] = name
+			candidates = append(candidates, short)
+		}
+	}
+
+	err = c.walkEnvFields(rv, c.rootPathForType(rv.Type()), func(fm fieldMeta, _ []string, _ reflect.Value) error {
+		name := envFieldName(c.envPrefix, fm.mapTag)
+		add(name)
+		if c.fileEnvEnabled(fm) {
+			add(name + fileEnvSuffix)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unknown env: %w", err)
+	}
+	if c.cfgEnv != "" {
+		add(c.cfgEnv)
+	}
+
+	var unknown []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(strings.ToUpper(name), prefix) {
+			continue
+		}
+		if _, ok := canonical[strings.TrimPrefix(strings.ToUpper(name), prefix)]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	errs := make([]error, 0, len(unknown))
+	for _, name := range unknown {
+		short := strings.TrimPrefix(strings.ToUpper(name), prefix)
+		errs = append(errs, &UnknownKeyError{
+			Source:      "env",
+			Key:         name,
+			Suggestions: canonicalSuggestions(short, candidates, canonical),
+		})
+	}
+	return errors.Join(errs...)
+}
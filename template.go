@@ -0,0 +1,232 @@
+package autoconfig
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// templateEntry is one key of a generated config template, in field order.
+type templateEntry struct {
+	key      string
+	desc     string
+	required bool
+	// unset marks required fields without a value. They are commented out in
+	// YAML and left out elsewhere so that Check keeps asking for them.
+	unset    bool
+	value    any
+	children []templateEntry
+}
+
+// InitFile writes a starter config file to the resolved path when none
+// exists; otherwise it returns an error wrapping fs.ErrExist. The template
+// holds every field of s with its current value, or its default= value when
+// zero. desc= texts become comments and required fields are marked.
+//
+// YAML templates carry the comments, and required fields without a value are
+// commented out. Other formats cannot hold comments, so they contain the
+// values only and leave such fields out. Either way the file reads back with
+// ReadFile, and Check keeps reporting the required fields until they are set.
+func (c *Config) InitFile(s any) error {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return fmt.Errorf("initfile: %w", err)
+	}
+
+	path := c.Path()
+	if path == "" {
+		return fmt.Errorf("initfile: Create or Resolve must be called first")
+	}
+
+	entry, ok := lookupCodec(c.cfgType)
+	if !ok {
+		return fmt.Errorf("initfile: unsupported config type %d", c.cfgType)
+	}
+
+	entries, err := c.templateEntries(rv, entry.codec.TagName())
+	if err != nil {
+		return fmt.Errorf("initfile: %w", err)
+	}
+
+	var data []byte
+	if c.cfgType == ConfigTypeYAML {
+		data, err = renderYAMLTemplate(entries)
+	} else {
+		data, err = entry.codec.Encode(templateSettings(entries))
+	}
+	if err != nil {
+		return fmt.Errorf("initfile: encoding %s: %w", entry.name, err)
+	}
+
+	if err := c.writeAtomic(path, data, false); err != nil {
+		return fmt.Errorf("initfile: %w", err)
+	}
+	return nil
+}
+
+func (c *Config) templateEntries(rv reflect.Value, tagName string) ([]templateEntry, error) {
+	metas, err := c.getOrBuildFieldMeta(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]templateEntry, 0, len(metas))
+	for _, fm := range metas {
+		key := fileKeyForField(fm, tagName)
+		if key == "" {
+			continue
+		}
+
+		te := templateEntry{key: key, desc: fm.desc, required: fm.required}
+		fv := rv.FieldByIndex(fm.index)
+
+		if fm.isStruct {
+			nested, ok := nestedTemplateValue(fv)
+			if ok {
+				te.children, err = c.templateEntries(nested, tagName)
+				if err != nil {
+					return nil, fmt.Errorf("nested struct %q: %w", fm.name, err)
+				}
+				entries = append(entries, te)
+				continue
+			}
+		}
+
+		if isZeroValue(fv) && fm.defaultVal != nil {
+			fv = reflect.New(fv.Type()).Elem()
+			if err := setValueFromString(fv, fm.name, "default", *fm.defaultVal); err != nil {
+				return nil, err
+			}
+		}
+
+		value, ok, err := c.encodeValue(fv, tagName)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", fm.name, err)
+		}
+		if !ok {
+			value, _, err = c.encodeValue(zeroTemplateValue(fv.Type()), tagName)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", fm.name, err)
+			}
+		}
+
+		te.value = value
+		te.unset = fm.required && fm.defaultVal == nil && isZeroValue(fv)
+		entries = append(entries, te)
+	}
+
+	return entries, nil
+}
+
+// nestedTemplateValue returns the struct behind fv, using a zero value for
+// nil pointers so that templates list nested keys too.
+func nestedTemplateValue(fv reflect.Value) (reflect.Value, bool) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv = reflect.New(fv.Type().Elem()).Elem()
+			continue
+		}
+		fv = fv.Elem()
+	}
+	return fv, fv.Kind() == reflect.Struct
+}
+
+// zeroTemplateValue is the value shown for nil pointers, slices and maps.
+func zeroTemplateValue(t reflect.Type) reflect.Value {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return reflect.MakeSlice(t, 0, 0)
+	case reflect.Map:
+		return reflect.MakeMap(t)
+	default:
+		return reflect.New(t).Elem()
+	}
+}
+
+func templateSettings(entries []templateEntry) map[string]any {
+	settings := make(map[string]any, len(entries))
+	for _, te := range entries {
+		switch {
+		case te.children != nil:
+			settings[te.key] = templateSettings(te.children)
+		case !te.unset:
+			settings[te.key] = te.value
+		}
+	}
+	return settings
+}
+
+func renderYAMLTemplate(entries []templateEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeYAMLEntries(&buf, entries, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeYAMLEntries(buf *bytes.Buffer, entries []templateEntry, indent string) error {
+	for i, te := range entries {
+		if i > 0 && (te.desc != "" || te.children != nil) {
+			buf.WriteString("\n")
+		}
+		for _, line := range strings.Split(te.desc, "\n") {
+			if line != "" {
+				fmt.Fprintf(buf, "%s# %s\n", indent, line)
+			}
+		}
+		if te.required {
+			fmt.Fprintf(buf, "%s# required\n", indent)
+		}
+
+		key, err := yamlScalar(te.key)
+		if err != nil {
+			return err
+		}
+
+		if te.children != nil {
+			fmt.Fprintf(buf, "%s%s:\n", indent, key)
+			if err := writeYAMLEntries(buf, te.children, indent+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+
+		prefix := indent
+		if te.unset {
+			prefix += "# "
+		}
+
+		out, err := yaml.Marshal(te.value)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", te.key, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		// Scalars, flow collections and block scalar headers such as |-
+		// stay on the key's line; block collections start below it.
+		if len(lines) == 1 || strings.HasPrefix(lines[0], "|") || strings.HasPrefix(lines[0], ">") {
+			fmt.Fprintf(buf, "%s%s: %s\n", prefix, key, lines[0])
+			lines = lines[1:]
+		} else {
+			fmt.Fprintf(buf, "%s%s:\n", prefix, key)
+		}
+		for _, line := range lines {
+			fmt.Fprintf(buf, "%s  %s\n", prefix, line)
+		}
+	}
+	return nil
+}
+
+// yamlScalar renders s as a YAML scalar, quoting it when needed.
+func yamlScalar(s string) (string, error) {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
@@ -0,0 +1,225 @@
+package autoconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// WriteFile encodes s with the codec of the resolved config file and writes
+// it there. Like viper's SafeWriteConfig it refuses to replace an existing
+// file; the error then wraps fs.ErrExist. Use OverwriteFile to replace it.
+//
+// Keys are the lower-cased mapstructure keys, or field names without one,
+// which is what ReadFile decodes, so the file reads back with ReadFile. Fields
+// with config:"-", fields whose codec struct tag is "-" and nil pointers are
+// left out. The file is written atomically
+// with mode 0600, creating its directory with mode 0700 when missing.
+func (c *Config) WriteFile(s any) error {
+	return c.writeFile(s, false)
+}
+
+// OverwriteFile is WriteFile, but replaces an existing file.
+func (c *Config) OverwriteFile(s any) error {
+	return c.writeFile(s, true)
+}
+
+func (c *Config) writeFile(s any, overwrite bool) error {
+	path := c.Path()
+	if path == "" {
+		return fmt.Errorf("writefile: Create or Resolve must be called first")
+	}
+
+	data, err := c.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("writefile: %w", err)
+	}
+
+	if err := c.writeAtomic(path, data, overwrite); err != nil {
+		return fmt.Errorf("writefile: %w", err)
+	}
+	return nil
+}
+
+// Marshal encodes s as WriteFile would, without writing it anywhere.
+func (c *Config) Marshal(s any) ([]byte, error) {
+	rv, err := structValueFromPointer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := lookupCodec(c.cfgType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported config type %d", c.cfgType)
+	}
+
+	settings, err := c.encodeStruct(rv, entry.codec.TagName())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := entry.codec.Encode(settings)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", entry.name, err)
+	}
+	return data, nil
+}
+
+// writeAtomic writes data to a temporary file next to path, syncs it and
+// renames it into place.
+func (c *Config) writeAtomic(path string, data []byte, overwrite bool) error {
+	if !overwrite {
+		if _, err := c.fs.Stat(path); err == nil {
+			return fmt.Errorf("%q: %w", filepath.Base(path), fs.ErrExist)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if err := c.fs.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating %q directory: %w", dir, err)
+	}
+
+	tmp, err := afero.TempFile(c.fs, dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = c.fs.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing %q: %w", tmpName, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("syncing %q: %w", tmpName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing %q: %w", tmpName, err)
+	}
+	if err := c.fs.Chmod(tmpName, 0o600); err != nil {
+		return fmt.Errorf("setting permissions on %q: %w", tmpName, err)
+	}
+	if err := c.fs.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("replacing %q: %w", filepath.Base(path), err)
+	}
+
+	committed = true
+	return nil
+}
+
+// encodeStruct converts rv to the settings map a codec encodes.
+func (c *Config) encodeStruct(rv reflect.Value, tagName string) (map[string]any, error) {
+	metas, err := c.getOrBuildFieldMeta(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]any, len(metas))
+	for _, fm := range metas {
+		key := fileKeyForField(fm, tagName)
+		if key == "" {
+			continue
+		}
+
+		value, ok, err := c.encodeValue(rv.FieldByIndex(fm.index), tagName)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", fm.name, err)
+		}
+		if ok {
+			settings[key] = value
+		}
+	}
+	return settings, nil
+}
+
+func (c *Config) encodeValue(v reflect.Value, tagName string) (any, bool, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false, nil
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == timeDurationType:
+		return time.Duration(v.Int()).String(), true, nil
+	case v.Type() == reflect.TypeOf(time.Time{}):
+		return v.Interface(), true, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		nested, err := c.encodeStruct(v, tagName)
+		return nested, true, err
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, false, nil
+		}
+		items := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, ok, err := c.encodeValue(v.Index(i), tagName)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				items = append(items, item)
+			}
+		}
+		return items, true, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false, nil
+		}
+		entries := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			item, ok, err := c.encodeValue(iter.Value(), tagName)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				entries[fmt.Sprint(iter.Key().Interface())] = item
+			}
+		}
+		return entries, true, nil
+
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, false, fmt.Errorf("unsupported type %s", v.Type())
+
+	default:
+		return v.Interface(), true, nil
+	}
+}
+
+// fileKeyForField returns the key a field is written under, or "" when the
+// field is excluded from files. It is the key the strict decoder accepts: the
+// codec tag named by tagName only excludes a field with "-", since ReadFile
+// decodes by mapstructure key and would reject any other spelling.
+func fileKeyForField(fm fieldMeta, tagName string) string {
+	if tagName != "" && cleanTagValue(fm.tags.Get(tagName)) == "-" {
+		return ""
+	}
+	if fm.mapTag == "-" {
+		return ""
+	}
+	if fm.mapTag != "" {
+		return strings.ToLower(fm.mapTag)
+	}
+	return strings.ToLower(fm.name)
+}